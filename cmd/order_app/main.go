@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -15,9 +18,12 @@ import (
 	"order/internal/infrastructure/cache"
 	"order/internal/infrastructure/database"
 	"order/internal/model"
+	"order/internal/service"
+	"order/internal/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lmittmann/tint"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func main() {
@@ -25,23 +31,42 @@ func main() {
 
 	initLogger(&cfg.Service)
 
+	initValidation(&cfg.Validation)
+	shutdownTracing := initTracing(cfg)
+
 	orderCache := initCache(cfg)
 	repo := initRepo(cfg, orderCache)
+	ingest := service.NewIngestService(repo, kafka.ValidateOrder)
+	statuses := service.NewStatusService(repo, kafka.ValidateStruct)
+	tracking := service.NewTrackingService(repo, kafka.ValidateStruct)
+	refunds := service.NewRefundService(repo, kafka.ValidateStruct)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	consumer := kafka.NewConsumer(
-		cfg.Kafka.Broker,
-		cfg.Kafka.Topic,
-		cfg.Kafka.Group,
-		cfg.Kafka.TopicDLQ,
-	)
+	if cfg.Validation.RulesFile != "" {
+		go kafka.WatchRules(ctx, cfg.Validation.RulesFile, cfg.Validation.RulesReload)
+	}
+
+	consumer := kafka.NewConsumer(cfg.Kafka)
+	statusConsumer := kafka.NewEventConsumer[model.StatusEvent](cfg.Kafka, cfg.Kafka.StatusTopic, cfg.Kafka.StatusTopicDLQ, cfg.Kafka.Group+"-status")
+	trackingConsumer := kafka.NewEventConsumer[model.TrackingEvent](cfg.Kafka, cfg.Kafka.TrackingTopic, cfg.Kafka.TrackingTopicDLQ, cfg.Kafka.Group+"-tracking")
+	refundConsumer := kafka.NewEventConsumer[model.Refund](cfg.Kafka, cfg.Kafka.RefundTopic, cfg.Kafka.RefundTopicDLQ, cfg.Kafka.Group+"-refunds")
 
-	go runKafkaConsumer(ctx, consumer, repo)
+	var warmedUp atomic.Bool
+	health := initHealth(repo, consumer, &warmedUp)
 
-	srv := runHTTPServer(cfg, repo)
+	srv := runHTTPServer(cfg, repo, ingest, refunds, health)
+
+	// The consumer starts after the warm-up, so restored orders never overwrite fresher ones in the cache.
+	go func() {
+		warmUpCache(repo, cfg.Service.CacheSize, &warmedUp)
+		go runEventConsumer(ctx, "status", statusConsumer, statuses.Apply)
+		go runEventConsumer(ctx, "tracking", trackingConsumer, tracking.Record)
+		go runEventConsumer(ctx, "refunds", refundConsumer, refunds.Record)
+		runKafkaConsumer(ctx, consumer, ingest)
+	}()
 
-	gracefulShutdown(srv, consumer, cancel)
+	gracefulShutdown(srv, consumer, []io.Closer{statusConsumer, trackingConsumer, refundConsumer}, cancel, shutdownTracing, health)
 }
 
 func initLogger(cfg *config.ServiceConfig) {
@@ -65,6 +90,34 @@ func initLogger(cfg *config.ServiceConfig) {
 	slog.Info("Logger initialized", "level", cfg.LogLevel.String(), "format", cfg.LogFormat)
 }
 
+func initTracing(cfg *config.Config) func(context.Context) error {
+	shutdown, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		slog.Error("Failed to initialize tracing", "err", err)
+		os.Exit(1)
+	}
+	slog.Info("Tracing initialized", "exporter", cfg.Tracing.Exporter)
+	return shutdown
+}
+
+func initValidation(cfg *config.ValidationConfig) {
+	if err := kafka.SetAllowedLocales(cfg.Locales); err != nil {
+		slog.Error("Invalid VALIDATION_LOCALES", "err", err)
+		os.Exit(1)
+	}
+
+	if cfg.RulesFile == "" {
+		return
+	}
+	rules, err := kafka.LoadRules(cfg.RulesFile)
+	if err != nil {
+		slog.Error("Invalid validation rules file", "err", err)
+		os.Exit(1)
+	}
+	kafka.SetRules(rules)
+	slog.Info("Validation rules loaded", "path", cfg.RulesFile, "rules", len(rules.Rules), "overrides", len(rules.Overrides))
+}
+
 func initCache(cfg *config.Config) *cache.OrderCache {
 	c, err := cache.NewOrderCache(cfg.Service.CacheSize)
 	if err != nil {
@@ -82,32 +135,60 @@ func initRepo(cfg *config.Config, orderCache *cache.OrderCache) *database.Reposi
 		os.Exit(1)
 	}
 
-	repo := database.NewRepository(db, orderCache)
-	slog.Info("Repository initialized")
+	policy, err := database.ParseConflictPolicy(cfg.Service.ConflictPolicy)
+	if err != nil {
+		slog.Error("Invalid ORDER_CONFLICT_POLICY", "err", err)
+		os.Exit(1)
+	}
+
+	repo := database.NewRepository(db, orderCache, policy)
+	slog.Info("Repository initialized", "conflict_policy", policy)
+	return repo
+}
 
-	if err := repo.RestoreCache(cfg.Service.CacheSize); err != nil {
+// warmUpCache restores the cache from the database and marks the warm-up as done.
+// The service stays not-ready until then.
+func warmUpCache(repo *database.Repository, cacheSize int, warmedUp *atomic.Bool) {
+	if err := repo.RestoreCache(context.Background(), cacheSize); err != nil {
 		slog.Error("Failed to restore cache from DB", "err", err)
 		os.Exit(1)
 	}
+	warmedUp.Store(true)
+}
 
-	return repo
+func initHealth(repo *database.Repository, consumer *kafka.Consumer, warmedUp *atomic.Bool) *httpDelivery.HealthHandler {
+	health := httpDelivery.NewHealthHandler()
+	health.AddCheck("postgres", repo.Ping)
+	health.AddCheck("kafka", consumer.PingBroker)
+	health.AddCheck("consumer_group", consumer.CheckGroupMembership)
+	health.AddCheck("cache_warmup", func(context.Context) error {
+		if !warmedUp.Load() {
+			return errors.New("cache warm-up in progress")
+		}
+		return nil
+	})
+	return health
 }
 
-func runKafkaConsumer(ctx context.Context, consumer *kafka.Consumer, repo *database.Repository) {
+func runKafkaConsumer(ctx context.Context, consumer *kafka.Consumer, ingest *service.IngestService) {
 	cLogger := slog.With("component", "kafka")
 
-	err := consumer.Start(ctx, func(orders []model.Order) error {
-		for i := range orders {
-			cLogger.Debug("Processing order", "order", orders[i])
-			_, err := repo.AddOrder(&orders[i])
-
-			if err != nil {
-				cLogger.Error("Failed to save order", "order_uid", orders[i].OrderUID, "err", err)
-			} else {
-				cLogger.Info("Order saved/updated", "order_uid", orders[i].OrderUID)
+	err := consumer.Start(ctx, func(ctx context.Context, source model.Source, orders []model.Order) []service.OrderResult {
+		cLogger.Debug("Processing orders", "count", len(orders))
+		results := ingest.Ingest(ctx, source, orders)
+		for _, res := range results {
+			switch res.Status {
+			case service.StatusAccepted:
+				cLogger.Info("Order saved/updated", "order_uid", res.OrderUID)
+			case service.StatusDuplicate:
+				cLogger.Info("Duplicate order skipped", "order_uid", res.OrderUID)
+			case service.StatusStale:
+				cLogger.Info("Stale order update skipped", "order_uid", res.OrderUID, "source", source.Name)
+			default:
+				cLogger.Warn("Order not saved", "order_uid", res.OrderUID, "status", res.Status, "err", res.Err)
 			}
 		}
-		return nil
+		return results
 	})
 
 	if err != nil {
@@ -115,11 +196,27 @@ func runKafkaConsumer(ctx context.Context, consumer *kafka.Consumer, repo *datab
 	}
 }
 
-func runHTTPServer(cfg *config.Config, repo *database.Repository) *http.Server {
+func runEventConsumer[T any](ctx context.Context, name string, consumer *kafka.EventConsumer[T], handle kafka.EventHandleFunc[T]) {
+	eLogger := slog.With("component", "kafka", "events", name)
+	eLogger.Info("Event consumer started")
+
+	err := consumer.Start(ctx, handle)
+	if err != nil {
+		eLogger.Error("Event consumer stopped", "err", err)
+	}
+}
+
+func runHTTPServer(cfg *config.Config, repo *database.Repository, ingest *service.IngestService, refunds *service.RefundService, health *httpDelivery.HealthHandler) *http.Server {
 	router := gin.New()
-	h := httpDelivery.NewHandler(repo)
+	// Probes are registered first, so they are neither traced nor measured.
+	health.RegisterRoutes(router)
+	router.Use(otelgin.Middleware(cfg.Tracing.ServiceName), httpDelivery.RequestMetrics())
+	h := httpDelivery.NewHandler(repo, ingest, refunds)
 	h.RegisterRoutes(router)
 
+	replayer := kafka.NewReplayer(cfg.Kafka.Broker, cfg.Kafka.TopicDLQ, cfg.Kafka.Topic)
+	httpDelivery.NewAdminHandler(cfg.Service.AdminToken, replayer).RegisterRoutes(router)
+
 	srv := &http.Server{
 		Addr:    ":" + cfg.Service.HTTPPort,
 		Handler: router,
@@ -135,12 +232,15 @@ func runHTTPServer(cfg *config.Config, repo *database.Repository) *http.Server {
 	return srv
 }
 
-func gracefulShutdown(srv *http.Server, consumer *kafka.Consumer, cancel context.CancelFunc) {
+func gracefulShutdown(srv *http.Server, consumer *kafka.Consumer, eventConsumers []io.Closer, cancel context.CancelFunc, shutdownTracing func(context.Context) error, health *httpDelivery.HealthHandler) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	sig := <-sigChan
 	slog.Warn("Shutting down service", "signal", sig)
 
+	// Report not-ready while shutting down
+	health.SetShuttingDown()
+
 	// Cancel Kafka consumer context
 	cancel()
 
@@ -150,6 +250,11 @@ func gracefulShutdown(srv *http.Server, consumer *kafka.Consumer, cancel context
 	} else {
 		slog.Info("Kafka consumer closed successfully")
 	}
+	for _, c := range eventConsumers {
+		if err := c.Close(); err != nil {
+			slog.Error("Error closing event consumer", "err", err)
+		}
+	}
 
 	// Shutdown HTTP server with timeout
 	ctxShut, cancelShut := context.WithTimeout(context.Background(), 5*time.Second)
@@ -160,5 +265,10 @@ func gracefulShutdown(srv *http.Server, consumer *kafka.Consumer, cancel context
 		slog.Info("HTTP server stopped gracefully")
 	}
 
+	// Flush pending spans
+	if err := shutdownTracing(ctxShut); err != nil {
+		slog.Error("Tracing shutdown error", "err", err)
+	}
+
 	slog.Info("Service stopped")
 }
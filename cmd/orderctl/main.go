@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"order/internal/config"
+	"order/internal/delivery/kafka"
+	"order/internal/infrastructure/database"
+)
+
+const usage = `Usage: orderctl <command> [flags]
+
+Commands:
+  dlq replay      Replay orders from the Dead Letter Queue
+  migrate up      Apply all pending schema migrations
+  migrate down    Roll back schema migrations (-steps, default 1)
+  migrate status  Show applied and pending schema migrations
+`
+
+func main() {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	if len(os.Args) < 3 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	cfg := config.LoadConfig()
+
+	var err error
+	switch cmd := os.Args[1] + " " + os.Args[2]; cmd {
+	case "dlq replay":
+		err = runDLQReplay(ctx, cfg, os.Args[3:])
+	case "migrate up", "migrate down", "migrate status":
+		err = runMigrate(ctx, cfg, os.Args[2], os.Args[3:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		slog.Error("Command failed", "err", err)
+		os.Exit(1)
+	}
+}
+
+// runDLQReplay parses replay flags, runs the replay and prints the report as JSON.
+func runDLQReplay(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("dlq replay", flag.ExitOnError)
+	fromOffset := fs.Int64("from-offset", -1, "start offset on every DLQ partition (takes precedence over -since)")
+	since := fs.String("since", "", "replay messages published at or after this RFC3339 time")
+	until := fs.String("until", "", "replay messages published at or before this RFC3339 time")
+	errorContains := fs.String("error", "", "replay only messages whose error header contains this text")
+	orderUID := fs.String("uid", "", "replay only the order with this UID")
+	dryRun := fs.Bool("dry-run", false, "validate matching orders without republishing them")
+	limit := fs.Int("limit", 0, "stop after this many matching orders (0 means no limit)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := kafka.ReplayOptions{
+		FromOffset:    *fromOffset,
+		ErrorContains: *errorContains,
+		OrderUID:      *orderUID,
+		DryRun:        *dryRun,
+		Limit:         *limit,
+	}
+
+	var err error
+	if opts.Since, err = parseTime(*since); err != nil {
+		return fmt.Errorf("invalid -since: %w", err)
+	}
+	if opts.Until, err = parseTime(*until); err != nil {
+		return fmt.Errorf("invalid -until: %w", err)
+	}
+
+	replayer := kafka.NewReplayer(cfg.Kafka.Broker, cfg.Kafka.TopicDLQ, cfg.Kafka.Topic)
+	report, err := replayer.Replay(ctx, opts)
+	if report != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if encErr := enc.Encode(report); encErr != nil {
+			return encErr
+		}
+	}
+	return err
+}
+
+// runMigrate runs a schema migration command against the configured database.
+func runMigrate(ctx context.Context, cfg *config.Config, action string, args []string) error {
+	fs := flag.NewFlagSet("migrate "+action, flag.ExitOnError)
+	steps := fs.Int("steps", 1, "number of migrations to roll back (migrate down only)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := database.OpenPostgres(cfg.DB)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	migrator, err := database.NewMigrator(sqlDB)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, v := range applied {
+			fmt.Printf("applied %04d\n", v)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return err
+	case "down":
+		rolledBack, err := migrator.Down(ctx, *steps)
+		for _, v := range rolledBack {
+			fmt.Printf("rolled back %04d\n", v)
+		}
+		return err
+	default:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = "applied " + st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-40s %s\n", st.Version, st.Name, applied)
+		}
+		fmt.Printf("latest version: %04d\n", migrator.Latest())
+		return nil
+	}
+}
+
+// parseTime parses an optional RFC3339 timestamp.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
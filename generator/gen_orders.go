@@ -59,8 +59,10 @@ func generateGoodOrders(n int, r *mathrand.Rand) []model.Order {
 func generateUpdateOrders(baseOrders []model.Order, r *mathrand.Rand) []model.Order {
 	updates := make([]model.Order, 0, len(baseOrders))
 	for _, order := range baseOrders {
+		order.Items = append([]model.Item(nil), order.Items...)
 		order.Items[0].Price += r.Intn(200)
-		order.DateCreated = fmt.Sprintf("2021-11-2%d06:22:19Z", r.Intn(9))
+		recalcTotals(&order)
+		order.DateCreated = randDate(r)
 		updates = append(updates, order)
 	}
 	return updates
@@ -73,20 +75,48 @@ func generateBadOrders(goodOrders []model.Order) []model.Order {
 	for _, order := range goodOrders {
 		bad := order
 		bad.OrderUID = fmt.Sprintf("invalid_uid_%03d", invalidUIDCounter)
-		bad.Payment.Transaction = bad.OrderUID
-		bad.Payment.Amount = -100
+		bad.Payments = append([]model.Payment(nil), order.Payments...)
+		bad.Payments[0].Transaction = bad.OrderUID
+		bad.Payments[0].Amount = -100
 		badOrders = append(badOrders, bad)
 		invalidUIDCounter++
 	}
 	return badOrders
 }
 
+// recalcTotals makes item and payment totals consistent with item prices and sales.
+// With a second payment (a gift card), it covers a third of the goods and the first payment the rest.
+func recalcTotals(order *model.Order) {
+	goods := 0
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.TotalPrice = item.Price * (100 - item.Sale) / 100
+		goods += item.TotalPrice
+	}
+	order.Payments = append([]model.Payment(nil), order.Payments...)
+	order.Payments[0].GoodsTotal = goods
+	if len(order.Payments) > 1 {
+		gift := &order.Payments[1]
+		gift.GoodsTotal = goods / 3
+		gift.Amount = gift.GoodsTotal
+		order.Payments[0].GoodsTotal -= gift.GoodsTotal
+	}
+	card := &order.Payments[0]
+	card.Amount = card.GoodsTotal + card.DeliveryCost + card.CustomFee
+}
+
+// randDate returns a random creation time in November 2021.
+func randDate(r *mathrand.Rand) model.Timestamp {
+	start := time.Date(2021, time.November, 1, 0, 0, 0, 0, time.UTC)
+	return model.NewTimestamp(start.Add(time.Duration(r.Int63n(int64(30 * 24 * time.Hour)))).Truncate(time.Second))
+}
+
 func newOrder(r *mathrand.Rand) model.Order {
 	uid := "b563" + randString(12)
 	track := "WBILM" + randString(8)
 	sizes := []string{"S", "M", "L", "XL"}
 
-	return model.Order{
+	order := model.Order{
 		OrderUID:          uid,
 		TrackNumber:       track,
 		Entry:             "WBIL",
@@ -96,7 +126,7 @@ func newOrder(r *mathrand.Rand) model.Order {
 		DeliveryService:   "meest",
 		Shardkey:          fmt.Sprintf("%d", r.Intn(10)+1),
 		SmID:              r.Intn(100),
-		DateCreated:       fmt.Sprintf("2021-11-2%d06:22:19Z", r.Intn(9)),
+		DateCreated:       randDate(r),
 		OofShard:          "1",
 		Delivery: model.Delivery{
 			Name:    "Test " + randString(5),
@@ -107,24 +137,24 @@ func newOrder(r *mathrand.Rand) model.Order {
 			Region:  "Region " + randString(3),
 			Email:   "test" + randString(5) + "@gmail.com",
 		},
-		Payment: model.Payment{
-			Transaction:  uid,
-			RequestID:    "",
-			Currency:     "USD",
-			Provider:     "wbpay",
-			Amount:       r.Intn(2000) + 200,
-			PaymentDt:    time.Now().Unix(),
-			Bank:         "alpha",
-			DeliveryCost: r.Intn(1000) + 100,
-			GoodsTotal:   r.Intn(1000) + 100,
-			CustomFee:    0,
+		Payments: []model.Payment{
+			{
+				Transaction:  uid,
+				RequestID:    "",
+				Currency:     "USD",
+				Provider:     "wbpay",
+				PaymentDt:    time.Now().Unix(),
+				Bank:         "alpha",
+				DeliveryCost: r.Intn(1000) + 100,
+				CustomFee:    0,
+			},
 		},
 		Items: []model.Item{
 			{
 				ChrtID:      r.Intn(1000000),
 				TrackNumber: track,
 				Price:       r.Intn(500) + 100,
-				Rid:         uid + "_item1",
+				Rid:         uid + "item1",
 				Name:        "Product " + randString(5),
 				Sale:        r.Intn(30),
 				Size:        sizes[r.Intn(len(sizes))],
@@ -135,6 +165,17 @@ func newOrder(r *mathrand.Rand) model.Order {
 			},
 		},
 	}
+	if r.Intn(2) == 0 {
+		order.Payments = append(order.Payments, model.Payment{
+			Transaction: uid + "gift",
+			Currency:    "USD",
+			Provider:    "giftcard",
+			PaymentDt:   time.Now().Unix(),
+			Bank:        "wbgift",
+		})
+	}
+	recalcTotals(&order)
+	return order
 }
 
 func saveJSON(filename string, data interface{}) {
@@ -4,15 +4,19 @@ import (
 	"log/slog"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 // Config holds the application configuration
 type Config struct {
-	DB      DBConfig
-	Kafka   KafkaConfig
-	Service ServiceConfig
+	DB         DBConfig
+	Kafka      KafkaConfig
+	Service    ServiceConfig
+	Validation ValidationConfig
+	Tracing    TracingConfig
 }
 
 // DBConfig holds the database configuration
@@ -24,20 +28,75 @@ type DBConfig struct {
 	Port     string
 }
 
-// KafkaConfig holds the Kafka configuration
+// KafkaConfig holds the Kafka configuration.
+// StatusTopic carries order status events, consumed in the group Group+"-status";
+// events that can't be applied go to StatusTopicDLQ. TrackingTopic carries carrier
+// scan events the same way, in the group Group+"-tracking", and RefundTopic
+// carries payment refunds, in the group Group+"-refunds".
 type KafkaConfig struct {
-	Broker   string
-	Topic    string
-	TopicDLQ string
-	Group    string
+	Broker           string
+	Topic            string
+	TopicDLQ         string
+	Group            string
+	StatusTopic      string
+	StatusTopicDLQ   string
+	TrackingTopic    string
+	TrackingTopicDLQ string
+	RefundTopic      string
+	RefundTopicDLQ   string
+	Retry            RetryConfig
+	Workers          WorkersConfig
 }
 
-// ServiceConfig holds the service-specific configuration
+// WorkersConfig holds the concurrency settings of the Kafka consumer.
+// Ordering is "partition" to process each partition sequentially or "key"
+// to only keep messages with the same key (order UID) in order.
+type WorkersConfig struct {
+	Count       int
+	MaxInFlight int
+	Ordering    string
+}
+
+// RetryConfig holds the retry policy for orders that failed to be stored.
+// When Topic is set, orders that still fail after Attempts in-process tries
+// are moved to the retry topic and retried there up to TopicAttempts times.
+type RetryConfig struct {
+	Attempts      int
+	Backoff       time.Duration
+	MaxBackoff    time.Duration
+	Topic         string
+	TopicAttempts int
+}
+
+// ValidationConfig holds the order validation settings.
+// Locales lists the accepted order locales, e.g. "en" or "en-US".
+// RulesFile is an optional YAML or JSON file with extra validation rules,
+// checked for changes every RulesReload (0 or less turns hot reload off).
+type ValidationConfig struct {
+	Locales     []string
+	RulesFile   string
+	RulesReload time.Duration
+}
+
+// TracingConfig holds the OpenTelemetry settings.
+// Exporter is "none", "stdout" or "otlp"; Endpoint is the OTLP/HTTP URL,
+// the OTEL_EXPORTER_OTLP_* variables are used when it is empty.
+type TracingConfig struct {
+	Exporter    string
+	Endpoint    string
+	ServiceName string
+}
+
+// ServiceConfig holds the service-specific configuration.
+// ConflictPolicy decides what happens to an update older than the stored order:
+// "reject", "newest" (skip it) or "overwrite".
 type ServiceConfig struct {
-	CacheSize int
-	HTTPPort  string
-	LogLevel  slog.Level
-	LogFormat string
+	CacheSize      int
+	HTTPPort       string
+	LogLevel       slog.Level
+	LogFormat      string
+	AdminToken     string
+	ConflictPolicy string
 }
 
 // LoadConfig loads configuration from environment variables and returns a config
@@ -61,16 +120,46 @@ func LoadConfig() *Config {
 			Port:     getEnv("DB_PORT", "5432"),
 		},
 		Kafka: KafkaConfig{
-			Broker:   getEnv("KAFKA_BROKER", "localhost:9092"),
-			Topic:    getEnv("KAFKA_TOPIC", "orders"),
-			TopicDLQ: getEnv("KAFKA_TOPIC_DLQ", "orders_dlq"),
-			Group:    getEnv("KAFKA_GROUP", "order-consumer-group"),
+			Broker:           getEnv("KAFKA_BROKER", "localhost:9092"),
+			Topic:            getEnv("KAFKA_TOPIC", "orders"),
+			TopicDLQ:         getEnv("KAFKA_TOPIC_DLQ", "orders_dlq"),
+			Group:            getEnv("KAFKA_GROUP", "order-consumer-group"),
+			StatusTopic:      getEnv("KAFKA_STATUS_TOPIC", "order-status"),
+			StatusTopicDLQ:   getEnv("KAFKA_STATUS_TOPIC_DLQ", "order-status-dlq"),
+			TrackingTopic:    getEnv("KAFKA_TRACKING_TOPIC", "order-tracking"),
+			TrackingTopicDLQ: getEnv("KAFKA_TRACKING_TOPIC_DLQ", "order-tracking-dlq"),
+			RefundTopic:      getEnv("KAFKA_REFUND_TOPIC", "order-refunds"),
+			RefundTopicDLQ:   getEnv("KAFKA_REFUND_TOPIC_DLQ", "order-refunds-dlq"),
+			Retry: RetryConfig{
+				Attempts:      getEnvInt("KAFKA_RETRY_ATTEMPTS", 3),
+				Backoff:       getEnvDuration("KAFKA_RETRY_BACKOFF", 500*time.Millisecond),
+				MaxBackoff:    getEnvDuration("KAFKA_RETRY_MAX_BACKOFF", 10*time.Second),
+				Topic:         getEnv("KAFKA_RETRY_TOPIC", ""),
+				TopicAttempts: getEnvInt("KAFKA_RETRY_TOPIC_ATTEMPTS", 5),
+			},
+			Workers: WorkersConfig{
+				Count:       getEnvInt("KAFKA_WORKERS", 4),
+				MaxInFlight: getEnvInt("KAFKA_MAX_IN_FLIGHT", 64),
+				Ordering:    getEnv("KAFKA_ORDERING", "partition"),
+			},
 		},
 		Service: ServiceConfig{
-			CacheSize: cacheSize,
-			HTTPPort:  getEnv("HTTP_PORT", "8081"),
-			LogLevel:  getLogLevel("LOG_LEVEL"),
-			LogFormat: getEnv("LOG_FORMAT", "json"),
+			CacheSize:      cacheSize,
+			HTTPPort:       getEnv("HTTP_PORT", "8081"),
+			LogLevel:       getLogLevel("LOG_LEVEL"),
+			LogFormat:      getEnv("LOG_FORMAT", "json"),
+			AdminToken:     getEnv("ADMIN_TOKEN", ""),
+			ConflictPolicy: getEnv("ORDER_CONFLICT_POLICY", "newest"),
+		},
+		Validation: ValidationConfig{
+			Locales:     getEnvList("VALIDATION_LOCALES", []string{"ru", "en"}),
+			RulesFile:   getEnv("VALIDATION_RULES_FILE", ""),
+			RulesReload: getEnvDuration("VALIDATION_RULES_RELOAD", 10*time.Second),
+		},
+		Tracing: TracingConfig{
+			Exporter:    getEnv("TRACING_EXPORTER", "none"),
+			Endpoint:    getEnv("TRACING_OTLP_ENDPOINT", ""),
+			ServiceName: getEnv("TRACING_SERVICE_NAME", "order-service"),
 		},
 	}
 }
@@ -83,6 +172,52 @@ func getEnv(key, defaultVal string) string {
 	return defaultVal
 }
 
+// getEnvList returns the comma-separated values of the environment variable or the default value if not set.
+// Blank entries are skipped.
+func getEnvList(key string, defaultVal []string) []string {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultVal
+	}
+	var list []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// getEnvInt returns the integer value of the environment variable or the default value if not set.
+// It exits the process if the value is not a valid integer.
+func getEnvInt(key string, defaultVal int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultVal
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Error("Invalid integer in environment", "key", key, "err", err)
+		os.Exit(1)
+	}
+	return n
+}
+
+// getEnvDuration returns the duration value of the environment variable or the default value if not set.
+// It exits the process if the value is not a valid duration such as "500ms" or "2s".
+func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultVal
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Error("Invalid duration in environment", "key", key, "err", err)
+		os.Exit(1)
+	}
+	return d
+}
+
 // getLogLevel reads LOG_LEVEL environment variable and returns slog.Level
 func getLogLevel(envVar string) slog.Level {
 	levelStr := os.Getenv(envVar)
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"order/internal/delivery/kafka"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler serves operational endpoints protected by a bearer token.
+type AdminHandler struct {
+	token    string
+	replayer *kafka.Replayer
+}
+
+// NewAdminHandler creates a new AdminHandler. Admin routes are disabled when token is empty.
+func NewAdminHandler(token string, replayer *kafka.Replayer) *AdminHandler {
+	return &AdminHandler{token: token, replayer: replayer}
+}
+
+// RegisterRoutes registers admin endpoints under /admin.
+func (h *AdminHandler) RegisterRoutes(r *gin.Engine) {
+	if h.token == "" {
+		slog.Warn("ADMIN_TOKEN is not set, admin endpoints are disabled")
+		return
+	}
+
+	admin := r.Group("/admin", h.authenticate)
+	admin.POST("/dlq/replay", h.replayDLQ)
+}
+
+// authenticate rejects requests without a valid "Authorization: Bearer <token>" header.
+func (h *AdminHandler) authenticate(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		slog.Warn("Unauthorized admin request", slog.String("path", c.FullPath()), slog.String("client_ip", c.ClientIP()))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	c.Next()
+}
+
+func (h *AdminHandler) replayDLQ(c *gin.Context) {
+	opts := kafka.ReplayOptions{FromOffset: -1}
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid replay options: " + err.Error()})
+		return
+	}
+
+	slog.Info("DLQ replay requested",
+		slog.Int64("from_offset", opts.FromOffset),
+		slog.String("order_uid", opts.OrderUID),
+		slog.Bool("dry_run", opts.DryRun),
+		slog.String("client_ip", c.ClientIP()),
+	)
+
+	report, err := h.replayer.Replay(c.Request.Context(), opts)
+	if err != nil {
+		if errors.Is(err, kafka.ErrInvalidReplayRange) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		slog.Error("DLQ replay failed", slog.String("error", err.Error()))
+		c.JSON(http.StatusBadGateway, gin.H{"error": "DLQ replay failed", "report": report})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
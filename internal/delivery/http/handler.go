@@ -1,29 +1,51 @@
 package http
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
 
 	db "order/internal/infrastructure/database"
+	"order/internal/metrics"
+	"order/internal/model"
+	"order/internal/service"
+	"order/internal/tracing"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Handler handles HTTP requests and interacts with the database repository.
 type Handler struct {
-	repo *db.Repository
+	repo    *db.Repository
+	ingest  *service.IngestService
+	refunds *service.RefundService
 }
 
-// NewHandler creates a new Handler with the given repository.
-func NewHandler(repo *db.Repository) *Handler {
-	return &Handler{repo: repo}
+// NewHandler creates a new Handler with the given repository, ingestion and refund services.
+func NewHandler(repo *db.Repository, ingest *service.IngestService, refunds *service.RefundService) *Handler {
+	return &Handler{repo: repo, ingest: ingest, refunds: refunds}
 }
 
 // RegisterRoutes registers all HTTP endpoints to the given Gin engine.
 func (h *Handler) RegisterRoutes(r *gin.Engine) {
 	r.Static("/static", "./static")
 	r.GET("/", h.serveHome)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	r.GET("/api/orders", h.listOrders)
+	r.POST("/api/orders", h.postOrders)
 	r.GET("/api/orders/:uid", h.getOrderByUID)
+	r.GET("/api/orders/:uid/history", h.getOrderHistory)
+	r.GET("/api/orders/:uid/diff", h.diffOrderVersions)
+	r.GET("/api/orders/:uid/status", h.getOrderStatus)
+	r.GET("/api/lookup/track/:track_number", h.getOrderByTrackNumber)
+	r.GET("/api/lookup/transaction/:transaction", h.getOrderByTransaction)
+	r.GET("/api/lookup/rid/:rid", h.getOrderByItemRID)
+	r.GET("/api/tracking/:track_number", h.getTrackingTimeline)
+	r.POST("/api/refunds", h.postRefund)
 }
 
 func (h *Handler) serveHome(c *gin.Context) {
@@ -32,24 +54,167 @@ func (h *Handler) serveHome(c *gin.Context) {
 }
 
 func (h *Handler) getOrderByUID(c *gin.Context) {
-	uid := c.Param("uid")
-	slog.Info("Fetching order", slog.String("uid", uid))
+	h.respondOrder(c, "uid", c.Param("uid"), h.repo.GetOrderWithCache)
+}
+
+func (h *Handler) getOrderByTrackNumber(c *gin.Context) {
+	h.respondOrder(c, "track_number", c.Param("track_number"), h.repo.GetOrderByTrackNumber)
+}
+
+func (h *Handler) getOrderByTransaction(c *gin.Context) {
+	h.respondOrder(c, "transaction", c.Param("transaction"), h.repo.GetOrderByTransaction)
+}
 
-	order, err := h.repo.GetOrderWithCache(uid)
+func (h *Handler) getOrderByItemRID(c *gin.Context) {
+	h.respondOrder(c, "rid", c.Param("rid"), h.repo.GetOrderByItemRID)
+}
+
+// respondOrder fetches a single order by the given key and writes it as JSON.
+func (h *Handler) respondOrder(c *gin.Context, field, value string, fetch func(context.Context, string) (*model.Order, error)) {
+	slog.Info("Fetching order", slog.String(field, value))
+
+	ctx := c.Request.Context()
+	order, err := fetch(ctx, value)
 	if err != nil {
 		slog.Error("Failed to get order",
-			slog.String("uid", uid),
+			slog.String(field, value),
 			slog.String("error", err.Error()),
 		)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get order"})
 		return
 	}
 	if order == nil {
-		slog.Warn("Order not found", slog.String("uid", uid))
+		slog.Warn("Order not found", slog.String(field, value))
 		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
 		return
 	}
 
-	slog.Info("Order fetched successfully", slog.String("uid", uid))
+	trace.SpanFromContext(ctx).SetAttributes(tracing.OrderUID.String(order.OrderUID))
+	slog.Info("Order fetched successfully", slog.String(field, value))
 	c.JSON(http.StatusOK, order)
 }
+
+func (h *Handler) listOrders(c *gin.Context) {
+	params := db.ListOrdersParams{
+		Filter: db.OrderFilter{
+			CustomerID:      c.Query("customer_id"),
+			DeliveryService: c.Query("delivery_service"),
+			PaymentProvider: c.Query("payment_provider"),
+			PaymentCurrency: c.Query("payment_currency"),
+			Locale:          c.Query("locale"),
+		},
+		Sort:   c.Query("sort"),
+		Cursor: c.Query("cursor"),
+	}
+
+	var ok bool
+	if params.Filter.DateFrom, ok = timeQuery(c, "date_from"); !ok {
+		return
+	}
+	if params.Filter.DateTo, ok = timeQuery(c, "date_to"); !ok {
+		return
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		params.Limit = n
+	}
+
+	page, err := h.repo.ListOrders(c.Request.Context(), params)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidCursor) || errors.Is(err, db.ErrInvalidSort) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		slog.Error("Failed to list orders", slog.String("error", err.Error()))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list orders"})
+		return
+	}
+
+	slog.Info("Orders listed", slog.Int("count", len(page.Orders)))
+	c.JSON(http.StatusOK, page)
+}
+
+// timeQuery parses an optional RFC 3339 query parameter.
+// It responds with 400 and returns false if the value is malformed.
+func timeQuery(c *gin.Context, name string) (time.Time, bool) {
+	v := c.Query(name)
+	if v == "" {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be an RFC 3339 timestamp"})
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func (h *Handler) postOrders(c *gin.Context) {
+	var orders []model.Order
+	if err := c.ShouldBindJSON(&orders); err != nil {
+		slog.Warn("Invalid orders payload", slog.String("error", err.Error()))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payload must be a JSON array of orders"})
+		return
+	}
+	if len(orders) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no orders in payload"})
+		return
+	}
+
+	source := model.Source{Name: "http:" + c.ClientIP(), Time: time.Now()}
+	results := h.ingest.Ingest(c.Request.Context(), source, orders)
+
+	var accepted, duplicates, stale, conflicts, rejected, failed int
+	for _, res := range results {
+		switch res.Status {
+		case service.StatusAccepted:
+			accepted++
+		case service.StatusDuplicate:
+			duplicates++
+		case service.StatusStale:
+			stale++
+		case service.StatusConflict:
+			conflicts++
+		case service.StatusRejected:
+			rejected++
+		default:
+			failed++
+		}
+	}
+
+	succeeded := accepted + duplicates + stale
+	status := http.StatusMultiStatus
+	switch {
+	case succeeded == len(results):
+		status = http.StatusOK
+	case failed > 0 && succeeded == 0:
+		status = http.StatusInternalServerError
+	case rejected == len(results):
+		status = http.StatusUnprocessableEntity
+	case conflicts == len(results):
+		status = http.StatusConflict
+	}
+
+	slog.Info("Orders ingested over HTTP",
+		slog.Int("accepted", accepted),
+		slog.Int("duplicates", duplicates),
+		slog.Int("stale", stale),
+		slog.Int("conflicts", conflicts),
+		slog.Int("rejected", rejected),
+		slog.Int("failed", failed),
+	)
+	c.JSON(status, gin.H{
+		"accepted":   accepted,
+		"duplicates": duplicates,
+		"stale":      stale,
+		"conflicts":  conflicts,
+		"rejected":   rejected,
+		"failed":     failed,
+		"results":    results,
+	})
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readyTimeout bounds the time all readiness checks together may take.
+const readyTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable. A nil error means the component is up.
+type Check func(ctx context.Context) error
+
+// componentStatus is the readiness of a single component in the /readyz response.
+type componentStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthHandler serves the liveness and readiness endpoints.
+type HealthHandler struct {
+	names        []string
+	checks       map[string]Check
+	shuttingDown atomic.Bool
+}
+
+// NewHealthHandler creates a HealthHandler without any readiness checks.
+func NewHealthHandler() *HealthHandler {
+	return &HealthHandler{checks: make(map[string]Check)}
+}
+
+// AddCheck registers a readiness check reported under name.
+// Checks must be added before the routes start serving.
+func (h *HealthHandler) AddCheck(name string, check Check) {
+	if _, ok := h.checks[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checks[name] = check
+}
+
+// SetShuttingDown makes /readyz report not-ready, so traffic is drained during graceful shutdown.
+func (h *HealthHandler) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// RegisterRoutes registers /healthz and /readyz.
+func (h *HealthHandler) RegisterRoutes(r *gin.Engine) {
+	r.GET("/healthz", h.healthz)
+	r.GET("/readyz", h.readyz)
+}
+
+// healthz reports that the process is alive. It does not check dependencies.
+func (h *HealthHandler) healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readyz runs all readiness checks concurrently and returns the status of each component.
+// It responds 503 if any component is down or the service is shutting down.
+func (h *HealthHandler) readyz(c *gin.Context) {
+	if h.shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+	defer cancel()
+
+	results := make([]componentStatus, len(h.names))
+	var wg sync.WaitGroup
+	for i, name := range h.names {
+		wg.Go(func() {
+			results[i] = componentStatus{Status: "up"}
+			if err := h.checks[name](ctx); err != nil {
+				results[i] = componentStatus{Status: "down", Error: err.Error()}
+			}
+		})
+	}
+	wg.Wait()
+
+	status, code := "ready", http.StatusOK
+	components := make(map[string]componentStatus, len(h.names))
+	for i, name := range h.names {
+		components[name] = results[i]
+		if results[i].Status != "up" {
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+	}
+	c.JSON(code, gin.H{"status": status, "components": components})
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"order/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getOrderHistory returns every stored version of an order, oldest first.
+func (h *Handler) getOrderHistory(c *gin.Context) {
+	uid := c.Param("uid")
+
+	versions, err := h.repo.GetOrderHistory(c.Request.Context(), uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get order history"})
+		return
+	}
+	if len(versions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		return
+	}
+
+	slog.Info("Order history fetched", slog.String("uid", uid), slog.Int("versions", len(versions)))
+	c.JSON(http.StatusOK, gin.H{"order_uid": uid, "versions": versions})
+}
+
+// diffOrderVersions lists the fields that changed between the versions given
+// by the "from" and "to" query parameters.
+func (h *Handler) diffOrderVersions(c *gin.Context) {
+	uid := c.Param("uid")
+
+	from, ok := versionQuery(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := versionQuery(c, "to")
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	versions := make([]*model.OrderVersion, 0, 2)
+	for _, n := range []int{from, to} {
+		v, err := h.repo.GetOrderVersion(ctx, uid, n)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get order version"})
+			return
+		}
+		if v == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order version " + strconv.Itoa(n) + " not found"})
+			return
+		}
+		versions = append(versions, v)
+	}
+
+	changes, err := model.DiffOrders(&versions[0].Snapshot, &versions[1].Snapshot)
+	if err != nil {
+		slog.Error("Failed to diff order versions", slog.String("uid", uid), slog.String("error", err.Error()))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to diff order versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order_uid": uid,
+		"from":      versionSummary(versions[0]),
+		"to":        versionSummary(versions[1]),
+		"changes":   changes,
+	})
+}
+
+// versionSummary describes a version without its snapshot.
+func versionSummary(v *model.OrderVersion) gin.H {
+	return gin.H{"version": v.Version, "source": v.Source, "created_at": v.CreatedAt}
+}
+
+// versionQuery parses a required positive version number from the query string.
+// It responds with 400 and returns false if the value is missing or malformed.
+func versionQuery(c *gin.Context, name string) (int, bool) {
+	n, err := strconv.Atoi(c.Query(name))
+	if err != nil || n <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a positive version number"})
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	db "order/internal/infrastructure/database"
+	"order/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// postRefund records a refund of a payment, the same way refund events are recorded.
+// A resent refund answers 200 with the current net amount instead of 201.
+func (h *Handler) postRefund(c *gin.Context) {
+	var refund model.Refund
+	if err := c.ShouldBindJSON(&refund); err != nil {
+		slog.Warn("Invalid refund payload", slog.String("error", err.Error()))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payload must be a JSON refund"})
+		return
+	}
+
+	source := model.Source{Name: "http:" + c.ClientIP(), Time: time.Now()}
+	order, err := h.refunds.Refund(c.Request.Context(), source, &refund)
+
+	var verr *model.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		slog.Warn("Refund rejected", slog.String("refund_id", refund.RefundID), slog.Any("error", verr))
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"status": "rejected", "violations": verr.Violations})
+	case errors.Is(err, db.ErrPaymentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "payment not found"})
+	case errors.Is(err, db.ErrDuplicateRefund):
+		c.JSON(http.StatusOK, gin.H{"status": "duplicate", "order_uid": order.OrderUID, "net_amount": order.NetAmount})
+	case err != nil:
+		slog.Error("Failed to record refund", slog.String("refund_id", refund.RefundID), slog.String("error", err.Error()))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record refund"})
+	default:
+		c.JSON(http.StatusCreated, gin.H{
+			"status":     "accepted",
+			"order_uid":  order.OrderUID,
+			"net_amount": order.NetAmount,
+			"refund":     refund,
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+
+	"order/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getOrderStatus returns the current status of an order and its transitions, oldest first.
+func (h *Handler) getOrderStatus(c *gin.Context) {
+	uid := c.Param("uid")
+	ctx := c.Request.Context()
+
+	order, err := h.repo.GetOrderWithCache(ctx, uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get order"})
+		return
+	}
+	if order == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		return
+	}
+
+	history, err := h.repo.GetStatusHistory(ctx, uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get status history"})
+		return
+	}
+
+	var updatedAt *model.Timestamp
+	if len(history) > 0 {
+		updatedAt = &history[len(history)-1].OccurredAt
+	}
+
+	slog.Info("Order status fetched", slog.String("uid", uid), slog.String("status", string(order.Status)))
+	c.JSON(http.StatusOK, gin.H{
+		"order_uid":  uid,
+		"status":     order.Status,
+		"updated_at": updatedAt,
+		"history":    history,
+	})
+}
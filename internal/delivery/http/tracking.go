@@ -0,0 +1,23 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getTrackingTimeline returns the carrier scans of a track number in the order they happened.
+// A track number without scans has an empty timeline.
+func (h *Handler) getTrackingTimeline(c *gin.Context) {
+	trackNumber := c.Param("track_number")
+
+	events, err := h.repo.GetTrackingTimeline(c.Request.Context(), trackNumber)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get tracking timeline"})
+		return
+	}
+
+	slog.Info("Tracking timeline fetched", slog.String("track_number", trackNumber), slog.Int("events", len(events)))
+	c.JSON(http.StatusOK, gin.H{"track_number": trackNumber, "events": events})
+}
@@ -3,41 +3,120 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
+	"order/internal/config"
+	"order/internal/metrics"
 	"order/internal/model"
+	"order/internal/service"
+	"order/internal/tracing"
 
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// Consumer consumes messages from a Kafka topic and validates orders.
+// errEmptyBatch is reported when a message holds an empty order array.
+var errEmptyBatch = errors.New("message contains no orders")
+
+// errResultMismatch is reported when a handler returns a result count that doesn't match its input.
+var errResultMismatch = errors.New("handler returned a result count that does not match the orders")
+
+// HandleFunc validates and stores a batch of orders and returns one result per order,
+// in the same order as the input. Rejected orders go to the DLQ, failed orders are
+// retried unless their error is wrapped with Permanent. ctx carries the trace of the message,
+// source identifies the message as "kafka:<topic>/<partition>@<offset>" with the time
+// the orders were first produced.
+type HandleFunc func(ctx context.Context, source model.Source, orders []model.Order) []service.OrderResult
+
+// messageReader is the subset of *kafka.Reader used by the Consumer.
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// messageWriter is the subset of *kafka.Writer used by the Consumer.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Consumer consumes messages from a Kafka topic and hands orders to the ingestion handler.
+// Partitions are processed in parallel on a worker pool. A message is committed only once
+// every order in it was stored or parked in the retry topic or DLQ.
 type Consumer struct {
-	reader    *kafka.Reader
-	dlqWriter *kafka.Writer
+	reader      messageReader
+	dlqWriter   messageWriter
+	retryReader messageReader
+	retryWriter messageWriter
+	retry       retryPolicy
+	pool        workerPool
+	groupID     string
+	broker      string
+	clientID    string
 }
 
-// NewConsumer creates a new Kafka consumer with the given broker, topic, group ID, and DLQ topic.
-func NewConsumer(broker, topic, groupID, dlqTopic string) *Consumer {
+// NewConsumer creates a new Kafka consumer from the Kafka configuration.
+// A retry topic reader and writer are created only when a retry topic is configured.
+// Readers use a client ID unique to this process, so CheckGroupMembership can find them in the group.
+func NewConsumer(cfg config.KafkaConfig) *Consumer {
+	clientID := consumerClientID()
+	dialer := &kafka.Dialer{
+		ClientID:  clientID,
+		Timeout:   10 * time.Second,
+		DualStack: true,
+	}
+
 	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: []string{broker},
-		Topic:   topic,
-		GroupID: groupID,
+		Brokers: []string{cfg.Broker},
+		Topic:   cfg.Topic,
+		GroupID: cfg.Group,
+		Dialer:  dialer,
 	})
 
 	dlqWriter := kafka.NewWriter(kafka.WriterConfig{
-		Brokers: []string{broker},
-		Topic:   dlqTopic,
+		Brokers: []string{cfg.Broker},
+		Topic:   cfg.TopicDLQ,
 	})
 
-	return &Consumer{reader: reader, dlqWriter: dlqWriter}
+	c := &Consumer{
+		reader:    reader,
+		dlqWriter: dlqWriter,
+		retry:     newRetryPolicy(cfg.Retry),
+		pool:      newWorkerPool(cfg.Workers),
+		groupID:   cfg.Group,
+		broker:    cfg.Broker,
+		clientID:  clientID,
+	}
+
+	if cfg.Retry.Topic != "" {
+		c.retryReader = kafka.NewReader(kafka.ReaderConfig{
+			Brokers: []string{cfg.Broker},
+			Topic:   cfg.Retry.Topic,
+			GroupID: cfg.Group,
+			Dialer:  dialer,
+		})
+		c.retryWriter = kafka.NewWriter(kafka.WriterConfig{
+			Brokers: []string{cfg.Broker},
+			Topic:   cfg.Retry.Topic,
+		})
+	}
+
+	return c
 }
 
-// Start begins consuming messages from Kafka and processes valid orders using the handle function.
-// Invalid messages or messages failing validation are sent to the DLQ.
-func (c *Consumer) Start(ctx context.Context, handle func(orders []model.Order) error) error {
+// Start begins consuming messages from Kafka and processes orders using the handle function.
+// Unparseable messages and rejected orders are sent to the DLQ. Orders that failed to be stored
+// are retried with exponential backoff, then moved to the retry topic (if configured) or the DLQ.
+// Start returns an error only if a message could not be resolved; it is left uncommitted.
+func (c *Consumer) Start(ctx context.Context, handle HandleFunc) error {
 	slog.Info("Kafka consumer started")
 	defer c.reader.Close()
 	defer c.dlqWriter.Close()
@@ -54,61 +133,276 @@ func (c *Consumer) Start(ctx context.Context, handle func(orders []model.Order)
 		cancel()
 	}()
 
-	for {
-		m, err := c.reader.ReadMessage(ctx)
-		if err != nil {
-			if ctx.Err() != nil {
-				return nil
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	if c.retryReader != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.consumeRetries(ctx, handle); err != nil {
+				slog.Error("Kafka retry consumer stopped", slog.String("error", err.Error()))
 			}
-			slog.Error("error reading message", slog.String("error", err.Error()))
-			continue
+		}()
+	}
+
+	return c.consume(ctx, c.reader, handle, func(kafka.Message) int { return 0 })
+}
+
+// consumeRetries processes orders from the retry topic. Every message waits
+// until its not-before time, so long backoffs don't block the main topic.
+func (c *Consumer) consumeRetries(ctx context.Context, handle HandleFunc) error {
+	slog.Info("Kafka retry consumer started")
+	defer c.retryReader.Close()
+	defer c.retryWriter.Close()
+
+	return c.consume(ctx, c.retryReader, handle, func(m kafka.Message) int {
+		attempt, notBefore := retryState(m)
+		// consume checks ctx right after the wait, so a cancelled sleep needs no handling here.
+		_ = sleep(ctx, time.Until(notBefore))
+		return attempt
+	})
+}
+
+// process runs a single message through the handler. Orders that fail with a
+// retryable error are retried in-process on the main topic only; retry topic
+// messages get one attempt per round. It returns nil once every order is
+// either stored or delivered to the retry topic or DLQ.
+func (c *Consumer) process(ctx context.Context, m kafka.Message, handle HandleFunc, retryAttempt int) error {
+	orders, err := unmarshalBatch(ctx, m)
+	if err != nil {
+		slog.Error("failed to unmarshal order", slog.String("error", err.Error()))
+		if err := c.deliver(ctx, c.dlqWriter, dlqMessage(m, c.groupID, m.Key, m.Value, err)); err != nil {
+			return err
 		}
+		metrics.DLQWrites.WithLabelValues(dlqCauseDecode).Inc()
+		return nil
+	}
+
+	attempts := c.retry.attempts
+	if retryAttempt > 0 {
+		attempts = 1
+	}
 
-		var orders []model.Order
-		if err := json.Unmarshal(m.Value, &orders); err != nil || len(orders) == 0 {
-			slog.Error("failed to unmarshal order", slog.String("error", err.Error()))
-			c.sendToDLQ(ctx, m)
+	pending := orders
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		results := handle(ctx, messageSource(m), pending)
+		metrics.KafkaHandleDuration.WithLabelValues(m.Topic).Observe(time.Since(start).Seconds())
+
+		routed, err := c.route(ctx, m, pending, results)
+		if err != nil {
+			return err
+		}
+		pending = routed.retry
+		if len(pending) == 0 {
+			return nil
+		}
+		if attempt >= attempts {
+			return c.escalate(ctx, m, pending, routed.reason, retryAttempt)
+		}
+
+		delay := c.retry.backoff.delay(attempt)
+		slog.Warn("orders failed to be stored, retrying",
+			slog.Int("attempt", attempt),
+			slog.Int("count", len(pending)),
+			slog.Duration("delay", delay),
+			slog.String("error", routed.reason.Error()),
+		)
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// messageSource identifies a message as the source of the orders it carries.
+// Retried and replayed messages keep the produce time of the original message,
+// so they don't pass for newer data than they hold.
+func messageSource(m kafka.Message) model.Source {
+	t := m.Time
+	if original, err := time.Parse(time.RFC3339Nano, headerValue(m.Headers, HeaderSourceTime)); err == nil {
+		t = original
+	}
+	return model.Source{
+		Name: fmt.Sprintf("kafka:%s/%d@%d", m.Topic, m.Partition, m.Offset),
+		Time: t,
+	}
+}
+
+// routedOrders are the orders of a handler call that failed with a retryable
+// error, and why they failed.
+type routedOrders struct {
+	retry  []model.Order
+	reason error
+}
+
+// route sends rejected and permanently failed orders to the DLQ and returns
+// the orders that failed with a retryable error. The error is set only if an
+// order could not be delivered to the DLQ.
+func (c *Consumer) route(ctx context.Context, src kafka.Message, orders []model.Order, results []service.OrderResult) (routedOrders, error) {
+	if len(results) != len(orders) {
+		slog.Error("handler result mismatch", slog.Int("orders", len(orders)), slog.Int("results", len(results)))
+		return routedOrders{retry: orders, reason: errResultMismatch}, nil
+	}
+
+	var failed []model.Order
+	var reasons []error
+	for i, res := range results {
+		switch {
+		case res.Status == service.StatusAccepted || res.Status == service.StatusDuplicate || res.Status == service.StatusStale:
 			continue
+		case res.Status == service.StatusRejected || res.Status == service.StatusConflict || IsPermanent(res.Err):
+			cause := dlqCauseFailed
+			switch res.Status {
+			case service.StatusRejected:
+				cause = dlqCauseRejected
+			case service.StatusConflict:
+				cause = dlqCauseConflict
+			}
+			if err := c.sendOrderToDLQ(ctx, src, &orders[i], res.Err, cause); err != nil {
+				return routedOrders{}, err
+			}
+		default:
+			failed = append(failed, orders[i])
+			reasons = append(reasons, res.Err)
 		}
+	}
+	return routedOrders{retry: failed, reason: errors.Join(reasons...)}, nil
+}
+
+// escalate moves orders that failed after the given retry topic attempt to the
+// next retry round, or to the DLQ once retry rounds are exhausted.
+func (c *Consumer) escalate(ctx context.Context, src kafka.Message, orders []model.Order, reason error, attempt int) error {
+	slog.Error("failed to store orders",
+		slog.Int("retry_attempt", attempt),
+		slog.Int("count", len(orders)),
+		slog.String("error", reason.Error()),
+	)
 
-		validOrders := make([]model.Order, 0, len(orders))
-		for _, order := range orders {
-			if err := ValidateOrder(&order); err != nil {
-				slog.Warn("invalid order", slog.String("order_uid", order.OrderUID), slog.String("error", err.Error()))
-				c.sendToDLQ(ctx, kafka.Message{
-					Key:   []byte(order.OrderUID),
-					Value: m.Value,
-				})
-				continue
+	if c.retryWriter != nil && attempt < c.retry.topicAttempts {
+		next := attempt + 1
+		notBefore := time.Now().Add(c.retry.backoff.delay(c.retry.attempts + next))
+		for i := range orders {
+			if err := c.sendOrderToRetry(ctx, src, &orders[i], reason, next, notBefore); err != nil {
+				return err
 			}
-			validOrders = append(validOrders, order)
 		}
+		return nil
+	}
 
-		if len(validOrders) == 0 {
-			continue
+	for i := range orders {
+		if err := c.sendOrderToDLQ(ctx, src, &orders[i], reason, dlqCauseFailed); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		if err := handle(validOrders); err != nil {
-			slog.Error("failed to handle orders", slog.String("error", err.Error()))
-			c.sendToDLQ(ctx, m)
-			continue
+// sendOrderToRetry publishes a single order to the retry topic.
+func (c *Consumer) sendOrderToRetry(ctx context.Context, src kafka.Message, order *model.Order, reason error, attempt int, notBefore time.Time) error {
+	ctx, span := startPublishSpan(ctx, "retry publish", order.OrderUID)
+	defer span.End()
+	span.SetAttributes(attribute.Int("retry.attempt", attempt))
+
+	value, err := json.Marshal([]*model.Order{order})
+	if err != nil {
+		return err
+	}
+
+	msg := retryMessage(src, c.groupID, []byte(order.OrderUID), value, reason, attempt, notBefore)
+	if err := c.deliver(ctx, c.retryWriter, msg); err != nil {
+		return err
+	}
+	slog.Warn("order scheduled for retry",
+		slog.String("order_uid", order.OrderUID),
+		slog.Int("attempt", attempt),
+		slog.Time("not_before", notBefore),
+	)
+	return nil
+}
+
+// sendOrderToDLQ sends a single order from the source message to the Dead Letter Queue.
+// cause is one of the dlqCause* values and only labels the DLQ writes metric.
+func (c *Consumer) sendOrderToDLQ(ctx context.Context, src kafka.Message, order *model.Order, reason error, cause string) error {
+	ctx, span := startPublishSpan(ctx, "dlq publish", order.OrderUID)
+	defer span.End()
+	span.SetAttributes(attribute.String("dlq.cause", cause))
+
+	value, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	if err := c.deliver(ctx, c.dlqWriter, dlqMessage(src, c.groupID, []byte(order.OrderUID), value, reason)); err != nil {
+		return err
+	}
+	metrics.DLQWrites.WithLabelValues(cause).Inc()
+	return nil
+}
+
+// deliver writes a message, retrying with backoff until it succeeds or ctx is done,
+// so an order is never dropped because the DLQ or retry topic is briefly unavailable.
+// The trace context of ctx is added to the message headers.
+func (c *Consumer) deliver(ctx context.Context, w messageWriter, msg kafka.Message) error {
+	return deliverWithBackoff(ctx, w, msg, c.retry.backoff)
+}
+
+// deliverWithBackoff writes a message with the trace context of ctx, retrying
+// with the given backoff until it succeeds or ctx is done.
+func deliverWithBackoff(ctx context.Context, w messageWriter, msg kafka.Message, b backoff) error {
+	injectTrace(ctx, &msg)
+	for attempt := 1; ; attempt++ {
+		err := w.WriteMessages(ctx, msg)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
 
-		if err := c.reader.CommitMessages(ctx, m); err != nil {
-			slog.Error("failed to commit message", slog.String("error", err.Error()))
+		delay := b.delay(attempt)
+		slog.Error("failed to write message, retrying",
+			slog.String("key", string(msg.Key)),
+			slog.Duration("delay", delay),
+			slog.String("error", err.Error()),
+		)
+		if err := sleep(ctx, delay); err != nil {
+			return err
 		}
 	}
 }
 
-// sendToDLQ sends a Kafka message to the Dead Letter Queue.
-func (c *Consumer) sendToDLQ(ctx context.Context, msg kafka.Message) {
-	if err := c.dlqWriter.WriteMessages(ctx, msg); err != nil {
-		slog.Error("failed to write message to DLQ", slog.String("error", err.Error()))
+// unmarshalBatch decodes the message value inside an "unmarshal" span.
+func unmarshalBatch(ctx context.Context, m kafka.Message) ([]model.Order, error) {
+	_, span := tracing.Tracer().Start(ctx, "unmarshal")
+	defer span.End()
+
+	orders, err := decodeBatch(m.Value)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
 	}
+	span.SetAttributes(attribute.Int("orders.count", len(orders)))
+	return orders, nil
 }
 
-// Close closes the Kafka consumer reader and DLQ writer.
+// decodeBatch parses a message value holding a JSON array of orders.
+func decodeBatch(value []byte) ([]model.Order, error) {
+	var orders []model.Order
+	if err := json.Unmarshal(value, &orders); err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, errEmptyBatch
+	}
+	return orders, nil
+}
+
+// Close closes the Kafka consumer readers and writers.
 func (c *Consumer) Close() error {
 	c.dlqWriter.Close()
+	if c.retryReader != nil {
+		c.retryReader.Close()
+		c.retryWriter.Close()
+	}
 	return c.reader.Close()
 }
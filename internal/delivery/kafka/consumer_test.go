@@ -0,0 +1,429 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"order/internal/infrastructure/database"
+	"order/internal/model"
+	"order/internal/service"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const testTopic = "orders"
+
+// fakeReader serves a fixed list of messages, then blocks until ctx is done.
+// onCommit, if set, is called for every commit before it is recorded.
+type fakeReader struct {
+	mu       sync.Mutex
+	msgs     []kafka.Message
+	commits  []kafka.Message
+	onCommit func(m kafka.Message)
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	r.mu.Lock()
+	if len(r.msgs) > 0 {
+		m := r.msgs[0]
+		r.msgs = r.msgs[1:]
+		r.mu.Unlock()
+		return m, nil
+	}
+	r.mu.Unlock()
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range msgs {
+		if r.onCommit != nil {
+			r.onCommit(m)
+		}
+		r.commits = append(r.commits, m)
+	}
+	return nil
+}
+
+func (r *fakeReader) Close() error { return nil }
+
+func (r *fakeReader) committed() []kafka.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.commits)
+}
+
+// fakeWriter records written messages. The first failures writes fail.
+type fakeWriter struct {
+	mu       sync.Mutex
+	failures int
+	msgs     []kafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failures > 0 {
+		w.failures--
+		return errors.New("broker unavailable")
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+// keys returns the keys of the written messages.
+func (w *fakeWriter) keys() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	keys := make([]string, len(w.msgs))
+	for i, m := range w.msgs {
+		keys[i] = string(m.Key)
+	}
+	return keys
+}
+
+// newTestConsumer creates a Consumer with a fake DLQ writer, no retry topic and
+// a backoff short enough for tests.
+func newTestConsumer(workers int, byKey bool, attempts int) *Consumer {
+	return &Consumer{
+		dlqWriter: &fakeWriter{},
+		retry: retryPolicy{
+			attempts:      attempts,
+			backoff:       backoff{initial: time.Millisecond, max: time.Millisecond},
+			topicAttempts: 1,
+		},
+		pool:    workerPool{workers: workers, maxInFlight: 16, byKey: byKey},
+		groupID: "test",
+	}
+}
+
+// orderMessage builds a main topic message holding orders with the given UIDs.
+func orderMessage(t *testing.T, partition int, offset int64, uids ...string) kafka.Message {
+	t.Helper()
+	orders := make([]model.Order, len(uids))
+	for i, uid := range uids {
+		orders[i].OrderUID = uid
+	}
+	value, err := json.Marshal(orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kafka.Message{
+		Topic:     testTopic,
+		Partition: partition,
+		Offset:    offset,
+		Key:       []byte(uids[0]),
+		Value:     value,
+		Time:      time.Now(),
+	}
+}
+
+// runConsumer consumes reader in the background. The returned function stops
+// the consumer and fails the test if it returned an error.
+func runConsumer(t *testing.T, c *Consumer, reader messageReader, handle HandleFunc) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- c.consume(ctx, reader, handle, func(kafka.Message) int { return 0 })
+	}()
+	return func() {
+		t.Helper()
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("consume returned %v", err)
+		}
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// fakeStore is an OrderStore whose saves fail with errs by order UID. Orders
+// listed in failures fail that many times with a retryable error first.
+// onSave, if set, is called before every save.
+type fakeStore struct {
+	mu       sync.Mutex
+	failures map[string]int
+	errs     map[string]error
+	calls    map[string]int
+	saved    []string
+	onSave   func(order *model.Order)
+}
+
+func (s *fakeStore) AddOrder(_ context.Context, order *model.Order, _ model.Source) (*model.Order, error) {
+	if s.onSave != nil {
+		s.onSave(order)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[order.OrderUID]++
+	if s.failures[order.OrderUID] > 0 {
+		s.failures[order.OrderUID]--
+		return nil, errors.New("connection refused")
+	}
+	if err := s.errs[order.OrderUID]; err != nil {
+		return nil, err
+	}
+	s.saved = append(s.saved, order.OrderUID)
+	return order, nil
+}
+
+func (s *fakeStore) stored() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Sorted(slices.Values(s.saved))
+}
+
+func accepted(uid string) service.OrderResult {
+	return service.OrderResult{OrderUID: uid, Status: service.StatusAccepted}
+}
+
+func failed(uid string, err error) service.OrderResult {
+	return service.OrderResult{OrderUID: uid, Status: service.StatusFailed, Err: err}
+}
+
+func TestConsumerDoesNotCommitWhileSaveFails(t *testing.T) {
+	reader := &fakeReader{msgs: []kafka.Message{orderMessage(t, 0, 0, "a")}}
+	c := newTestConsumer(1, false, 3)
+
+	var mu sync.Mutex
+	calls := 0
+	handle := func(_ context.Context, _ model.Source, orders []model.Order) []service.OrderResult {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if n := len(reader.committed()); n > 0 {
+			t.Errorf("attempt %d: %d offsets committed before the order was stored", calls, n)
+		}
+		if calls < 3 {
+			return []service.OrderResult{failed(orders[0].OrderUID, errors.New("connection refused"))}
+		}
+		return []service.OrderResult{accepted(orders[0].OrderUID)}
+	}
+
+	stop := runConsumer(t, c, reader, handle)
+	waitFor(t, "the commit", func() bool { return len(reader.committed()) > 0 })
+	stop()
+
+	if calls != 3 {
+		t.Errorf("handler called %d times, want 3", calls)
+	}
+	if commits := reader.committed(); len(commits) != 1 || commits[0].Offset != 0 {
+		t.Errorf("commits = %v, want offset 0 once", commits)
+	}
+	if keys := c.dlqWriter.(*fakeWriter).keys(); len(keys) > 0 {
+		t.Errorf("stored order sent to the DLQ: %v", keys)
+	}
+}
+
+func TestConsumerCommitsInOrderPerPartition(t *testing.T) {
+	const perPartition = 8
+
+	type position struct {
+		partition int
+		offset    int64
+	}
+	var msgs []kafka.Message
+	positions := map[string]position{}
+	for p := range 2 {
+		for o := range int64(perPartition) {
+			uid := fmt.Sprintf("order-%d-%d", p, o)
+			positions[uid] = position{p, o}
+			msgs = append(msgs, orderMessage(t, p, o, uid))
+		}
+	}
+
+	var mu sync.Mutex
+	handled := map[position]bool{}
+	last := map[int]int64{0: -1, 1: -1}
+
+	reader := &fakeReader{msgs: msgs}
+	reader.onCommit = func(m kafka.Message) {
+		mu.Lock()
+		defer mu.Unlock()
+		if m.Offset <= last[m.Partition] {
+			t.Errorf("partition %d: offset %d committed after %d", m.Partition, m.Offset, last[m.Partition])
+		}
+		last[m.Partition] = m.Offset
+		for o := range m.Offset + 1 {
+			if !handled[position{m.Partition, o}] {
+				t.Errorf("partition %d: offset %d committed before offset %d was handled", m.Partition, m.Offset, o)
+			}
+		}
+	}
+
+	// Key ordering spreads a partition over the workers; earlier offsets take
+	// longer, so they finish after later ones.
+	c := newTestConsumer(4, true, 1)
+	handle := func(_ context.Context, _ model.Source, orders []model.Order) []service.OrderResult {
+		pos := positions[orders[0].OrderUID]
+		time.Sleep(time.Duration(perPartition-pos.offset) * 2 * time.Millisecond)
+		mu.Lock()
+		handled[pos] = true
+		mu.Unlock()
+		return []service.OrderResult{accepted(orders[0].OrderUID)}
+	}
+
+	stop := runConsumer(t, c, reader, handle)
+	waitFor(t, "the last offsets", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return last[0] == perPartition-1 && last[1] == perPartition-1
+	})
+	stop()
+}
+
+func TestConsumerParksEveryFailedOrder(t *testing.T) {
+	handle := func(_ context.Context, _ model.Source, orders []model.Order) []service.OrderResult {
+		results := make([]service.OrderResult, len(orders))
+		for i, o := range orders {
+			switch o.OrderUID {
+			case "ok":
+				results[i] = accepted(o.OrderUID)
+			case "invalid":
+				results[i] = service.OrderResult{
+					OrderUID: o.OrderUID,
+					Status:   service.StatusRejected,
+					Err:      &model.ValidationError{Violations: []model.Violation{{Path: "order_uid", Rule: "required"}}},
+				}
+			case "broken":
+				results[i] = failed(o.OrderUID, Permanent(errors.New("value too long")))
+			default:
+				results[i] = failed(o.OrderUID, errors.New("connection refused"))
+			}
+		}
+		return results
+	}
+
+	tests := []struct {
+		name        string
+		retryTopic  bool
+		dlqFailures int
+		wantDLQ     []string
+		wantRetry   []string
+	}{
+		{
+			name:    "without retry topic",
+			wantDLQ: []string{"broken", "flaky", "invalid"},
+		},
+		{
+			name:       "with retry topic",
+			retryTopic: true,
+			wantDLQ:    []string{"broken", "invalid"},
+			wantRetry:  []string{"flaky"},
+		},
+		{
+			name:        "DLQ briefly unavailable",
+			dlqFailures: 2,
+			wantDLQ:     []string{"broken", "flaky", "invalid"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConsumer(1, false, 2)
+			dlq := &fakeWriter{failures: tt.dlqFailures}
+			retry := &fakeWriter{}
+			c.dlqWriter = dlq
+			if tt.retryTopic {
+				c.retryWriter = retry
+				c.retry.topicAttempts = 2
+			}
+
+			reader := &fakeReader{msgs: []kafka.Message{orderMessage(t, 0, 0, "ok", "invalid", "broken", "flaky")}}
+			reader.onCommit = func(kafka.Message) {
+				if got := len(dlq.keys()) + len(retry.keys()); got != len(tt.wantDLQ)+len(tt.wantRetry) {
+					t.Errorf("offset committed with %d of %d failed orders parked", got, len(tt.wantDLQ)+len(tt.wantRetry))
+				}
+			}
+
+			stop := runConsumer(t, c, reader, handle)
+			waitFor(t, "the commit", func() bool { return len(reader.committed()) > 0 })
+			stop()
+
+			if got := slices.Sorted(slices.Values(dlq.keys())); !slices.Equal(got, tt.wantDLQ) {
+				t.Errorf("DLQ = %v, want %v", got, tt.wantDLQ)
+			}
+			if got := slices.Sorted(slices.Values(retry.keys())); !slices.Equal(got, tt.wantRetry) {
+				t.Errorf("retry topic = %v, want %v", got, tt.wantRetry)
+			}
+			for _, m := range retry.msgs {
+				if got := headerValue(m.Headers, HeaderRetryAttempt); got != "1" {
+					t.Errorf("retry attempt header = %q, want 1", got)
+				}
+			}
+		})
+	}
+}
+
+func TestConsumerWithIngestService(t *testing.T) {
+	const attempts = 3
+	reader := &fakeReader{msgs: []kafka.Message{orderMessage(t, 0, 0, "ok", "flaky", "down", "broken", "older", "invalid")}}
+	c := newTestConsumer(1, false, attempts)
+	dlq := c.dlqWriter.(*fakeWriter)
+
+	store := &fakeStore{
+		failures: map[string]int{"flaky": attempts - 1},
+		errs: map[string]error{
+			"down":   errors.New("connection refused"),
+			"broken": Permanent(errors.New("value too long")),
+			"older":  database.ErrOrderConflict,
+		},
+		calls: map[string]int{},
+	}
+	store.onSave = func(order *model.Order) {
+		if n := len(reader.committed()); n > 0 {
+			t.Errorf("saving %s: %d offsets committed before the message was resolved", order.OrderUID, n)
+		}
+	}
+	validate := func(o *model.Order) error {
+		if o.OrderUID == "invalid" {
+			return &model.ValidationError{Violations: []model.Violation{{Path: "order_uid", Rule: "required"}}}
+		}
+		return nil
+	}
+	ingest := service.NewIngestService(store, validate)
+
+	wantStored := []string{"flaky", "ok"}
+	wantDLQ := []string{"broken", "down", "invalid", "older"}
+	reader.onCommit = func(kafka.Message) {
+		if got := slices.Sorted(slices.Values(dlq.keys())); !slices.Equal(got, wantDLQ) {
+			t.Errorf("offset committed with DLQ %v, want %v", got, wantDLQ)
+		}
+	}
+
+	stop := runConsumer(t, c, reader, ingest.Ingest)
+	waitFor(t, "the commit", func() bool { return len(reader.committed()) > 0 })
+	stop()
+
+	if got := store.stored(); !slices.Equal(got, wantStored) {
+		t.Errorf("stored %v, want %v", got, wantStored)
+	}
+	if got := slices.Sorted(slices.Values(dlq.keys())); !slices.Equal(got, wantDLQ) {
+		t.Errorf("DLQ = %v, want %v", got, wantDLQ)
+	}
+	for uid, want := range map[string]int{"ok": 1, "flaky": attempts, "down": attempts, "broken": 1, "older": 1, "invalid": 0} {
+		if got := store.calls[uid]; got != want {
+			t.Errorf("%s saved %d times, want %d", uid, got, want)
+		}
+	}
+}
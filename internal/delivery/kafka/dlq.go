@@ -0,0 +1,77 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"order/internal/model"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Headers attached to every message published to the Dead Letter Queue.
+const (
+	HeaderError           = "x-dlq-error"
+	HeaderSourceTopic     = "x-dlq-source-topic"
+	HeaderSourcePartition = "x-dlq-source-partition"
+	HeaderSourceOffset    = "x-dlq-source-offset"
+	HeaderConsumerGroup   = "x-dlq-consumer-group"
+	HeaderFailedAt        = "x-dlq-failed-at"
+	HeaderViolations      = "x-dlq-violations"
+	HeaderSourceTime      = "x-dlq-source-time"
+)
+
+// Causes of DLQ writes, used as metric labels.
+const (
+	dlqCauseDecode   = "decode"
+	dlqCauseRejected = "rejected"
+	dlqCauseFailed   = "failed"
+	dlqCauseConflict = "conflict"
+)
+
+// dlqMessage builds a DLQ message with the given key and value that records
+// where the source message came from and why it failed. If the source message
+// already carries source headers (it came from the retry topic), the original
+// source is kept so the DLQ points at the first failure.
+func dlqMessage(src kafka.Message, groupID string, key, value []byte, reason error) kafka.Message {
+	errMsg := "unknown error"
+	if reason != nil {
+		errMsg = reason.Error()
+	}
+
+	topic := src.Topic
+	partition := strconv.Itoa(src.Partition)
+	offset := strconv.FormatInt(src.Offset, 10)
+	sourceTime := src.Time.UTC().Format(time.RFC3339Nano)
+	if original := headerValue(src.Headers, HeaderSourceTopic); original != "" {
+		topic = original
+		partition = headerValue(src.Headers, HeaderSourcePartition)
+		offset = headerValue(src.Headers, HeaderSourceOffset)
+		sourceTime = headerValue(src.Headers, HeaderSourceTime)
+	}
+
+	msg := kafka.Message{
+		Key:   key,
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: HeaderError, Value: []byte(errMsg)},
+			{Key: HeaderSourceTopic, Value: []byte(topic)},
+			{Key: HeaderSourcePartition, Value: []byte(partition)},
+			{Key: HeaderSourceOffset, Value: []byte(offset)},
+			{Key: HeaderSourceTime, Value: []byte(sourceTime)},
+			{Key: HeaderConsumerGroup, Value: []byte(groupID)},
+			{Key: HeaderFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+		},
+	}
+
+	// Validation failures also carry their violations as JSON for DLQ tooling.
+	var verr *model.ValidationError
+	if errors.As(reason, &verr) {
+		if raw, err := json.Marshal(verr.Violations); err == nil {
+			msg.Headers = append(msg.Headers, kafka.Header{Key: HeaderViolations, Value: raw})
+		}
+	}
+	return msg
+}
@@ -0,0 +1,147 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"order/internal/config"
+	"order/internal/metrics"
+	"order/internal/model"
+	"order/internal/tracing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// EventHandleFunc handles a single event decoded from a message.
+// Errors that are a *model.ValidationError or wrapped with Permanent send the
+// message to the DLQ, other errors are retried.
+type EventHandleFunc[T any] func(ctx context.Context, source model.Source, event *T) error
+
+// EventConsumer consumes a topic of small per-order events, one JSON object per
+// message, for example order status changes. Messages are processed one at a time,
+// so the events of a partition (and of an order, keyed by order UID) stay in order.
+// A message is committed once its event is handled or parked in the DLQ.
+type EventConsumer[T any] struct {
+	reader    messageReader
+	dlqWriter messageWriter
+	retry     retryPolicy
+	groupID   string
+}
+
+// NewEventConsumer creates an EventConsumer reading topic in the consumer group groupID.
+// Failed events are retried as configured by cfg.Retry (the retry topic is not
+// used) and then written to dlqTopic.
+func NewEventConsumer[T any](cfg config.KafkaConfig, topic, dlqTopic, groupID string) *EventConsumer[T] {
+	return &EventConsumer[T]{
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers: []string{cfg.Broker},
+			Topic:   topic,
+			GroupID: groupID,
+		}),
+		dlqWriter: kafka.NewWriter(kafka.WriterConfig{
+			Brokers: []string{cfg.Broker},
+			Topic:   dlqTopic,
+		}),
+		retry:   newRetryPolicy(cfg.Retry),
+		groupID: groupID,
+	}
+}
+
+// Start consumes events until ctx is done. It returns an error only if a
+// message could not be resolved; it is left uncommitted.
+func (c *EventConsumer[T]) Start(ctx context.Context, handle EventHandleFunc[T]) error {
+	for {
+		m, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			slog.Error("error reading message", slog.String("error", err.Error()))
+			continue
+		}
+
+		metrics.KafkaMessagesConsumed.WithLabelValues(m.Topic).Inc()
+		metrics.KafkaConsumerLag.WithLabelValues(m.Topic, strconv.Itoa(m.Partition)).Set(float64(max(m.HighWaterMark-m.Offset-1, 0)))
+
+		spanCtx, span := startProcessSpan(ctx, m)
+		err = c.process(spanCtx, m, handle)
+		if err != nil {
+			tracing.RecordError(span, err)
+		}
+		span.End()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if err := c.reader.CommitMessages(ctx, m); err != nil {
+			metrics.KafkaCommitFailures.WithLabelValues(m.Topic).Inc()
+			slog.Error("failed to commit message", slog.String("topic", m.Topic), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// process handles a single message, retrying retryable errors with backoff,
+// and sends it to the DLQ if it can't be handled.
+func (c *EventConsumer[T]) process(ctx context.Context, m kafka.Message, handle EventHandleFunc[T]) error {
+	var event T
+	if err := json.Unmarshal(m.Value, &event); err != nil {
+		slog.Error("failed to unmarshal event", slog.String("topic", m.Topic), slog.String("error", err.Error()))
+		return c.sendToDLQ(ctx, m, err, dlqCauseDecode)
+	}
+
+	source := messageSource(m)
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := handle(ctx, source, &event)
+		metrics.KafkaHandleDuration.WithLabelValues(m.Topic).Observe(time.Since(start).Seconds())
+		if err == nil {
+			return nil
+		}
+
+		var verr *model.ValidationError
+		if errors.As(err, &verr) {
+			slog.Warn("event rejected", slog.String("topic", m.Topic), slog.Any("error", verr))
+			return c.sendToDLQ(ctx, m, err, dlqCauseRejected)
+		}
+		if IsPermanent(err) || attempt >= c.retry.attempts {
+			slog.Error("failed to handle event", slog.String("topic", m.Topic), slog.Int("attempt", attempt), slog.String("error", err.Error()))
+			return c.sendToDLQ(ctx, m, err, dlqCauseFailed)
+		}
+
+		delay := c.retry.backoff.delay(attempt)
+		slog.Warn("failed to handle event, retrying",
+			slog.String("topic", m.Topic),
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+			slog.String("error", err.Error()),
+		)
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// sendToDLQ writes the message unchanged to the DLQ with the failure headers.
+func (c *EventConsumer[T]) sendToDLQ(ctx context.Context, m kafka.Message, reason error, cause string) error {
+	ctx, span := startPublishSpan(ctx, "dlq publish", string(m.Key))
+	defer span.End()
+
+	if err := deliverWithBackoff(ctx, c.dlqWriter, dlqMessage(m, c.groupID, m.Key, m.Value, reason), c.retry.backoff); err != nil {
+		return err
+	}
+	metrics.DLQWrites.WithLabelValues(cause).Inc()
+	return nil
+}
+
+// Close closes the reader and the DLQ writer.
+func (c *EventConsumer[T]) Close() error {
+	c.dlqWriter.Close()
+	return c.reader.Close()
+}
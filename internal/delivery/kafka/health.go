@@ -0,0 +1,57 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// groupStateStable is the consumer group state once a rebalance has completed.
+const groupStateStable = "Stable"
+
+// consumerClientID returns a Kafka client ID unique to this process.
+func consumerClientID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("order-consumer-%s-%d", host, os.Getpid())
+}
+
+// PingBroker checks that the Kafka broker accepts connections.
+func (c *Consumer) PingBroker(ctx context.Context) error {
+	conn, err := (&kafka.Dialer{ClientID: c.clientID}).DialContext(ctx, "tcp", c.broker)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// CheckGroupMembership checks that the consumer group is stable and that this
+// consumer is one of its members.
+func (c *Consumer) CheckGroupMembership(ctx context.Context) error {
+	client := &kafka.Client{Addr: kafka.TCP(c.broker)}
+	resp, err := client.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{c.groupID}})
+	if err != nil {
+		return err
+	}
+	if len(resp.Groups) == 0 {
+		return fmt.Errorf("group %s not found", c.groupID)
+	}
+
+	group := resp.Groups[0]
+	if group.Error != nil {
+		return group.Error
+	}
+	if group.GroupState != groupStateStable {
+		return fmt.Errorf("group %s is %s", c.groupID, group.GroupState)
+	}
+	for _, m := range group.Members {
+		if m.ClientID == c.clientID {
+			return nil
+		}
+	}
+	return fmt.Errorf("consumer %s has not joined group %s", c.clientID, c.groupID)
+}
@@ -0,0 +1,321 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"order/internal/model"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// HeaderReplayedFrom marks orders republished from the DLQ with their DLQ position.
+const HeaderReplayedFrom = "x-dlq-replayed-from"
+
+// Replay actions reported for every DLQ message that matched the filters.
+const (
+	ReplayActionReplayed    = "replayed"
+	ReplayActionWouldReplay = "would_replay"
+	ReplayActionInvalid     = "invalid"
+	ReplayActionFailed      = "failed"
+)
+
+// ErrInvalidReplayRange is returned when the requested replay range is empty.
+var ErrInvalidReplayRange = errors.New("until must be after since")
+
+// ReplayOptions selects which DLQ messages are replayed.
+// FromOffset takes precedence over Since; a negative FromOffset means "not set".
+type ReplayOptions struct {
+	FromOffset    int64     `json:"from_offset"`
+	Since         time.Time `json:"since"`
+	Until         time.Time `json:"until"`
+	ErrorContains string    `json:"error_contains"`
+	OrderUID      string    `json:"order_uid"`
+	DryRun        bool      `json:"dry_run"`
+	Limit         int       `json:"limit"`
+}
+
+// Validate checks that the replay options are consistent.
+func (o ReplayOptions) Validate() error {
+	if !o.Since.IsZero() && !o.Until.IsZero() && !o.Until.After(o.Since) {
+		return ErrInvalidReplayRange
+	}
+	return nil
+}
+
+// ReplayItem describes the outcome for a single order found in the DLQ.
+type ReplayItem struct {
+	Partition  int               `json:"partition"`
+	Offset     int64             `json:"offset"`
+	OrderUID   string            `json:"order_uid,omitempty"`
+	Action     string            `json:"action"`
+	Error      string            `json:"error,omitempty"`
+	Violations []model.Violation `json:"violations,omitempty"`
+}
+
+// ReplayReport summarizes a replay run.
+type ReplayReport struct {
+	DryRun   bool         `json:"dry_run"`
+	Scanned  int          `json:"scanned"`
+	Matched  int          `json:"matched"`
+	Valid    int          `json:"valid"`
+	Invalid  int          `json:"invalid"`
+	Replayed int          `json:"replayed"`
+	Failed   int          `json:"failed"`
+	Items    []ReplayItem `json:"items"`
+}
+
+// Replayer reads the Dead Letter Queue and republishes selected orders
+// to their source topic so they go through the normal ingestion path.
+type Replayer struct {
+	broker      string
+	dlqTopic    string
+	targetTopic string
+}
+
+// NewReplayer creates a new Replayer. targetTopic is used for DLQ messages
+// that carry no source topic header.
+func NewReplayer(broker, dlqTopic, targetTopic string) *Replayer {
+	return &Replayer{broker: broker, dlqTopic: dlqTopic, targetTopic: targetTopic}
+}
+
+// Replay scans every partition of the DLQ topic within the requested range,
+// validates matching orders with ValidateOrder and, unless DryRun is set,
+// republishes the valid ones.
+func (r *Replayer) Replay(ctx context.Context, opts ReplayOptions) (*ReplayReport, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	conn, err := kafka.DialContext(ctx, "tcp", r.broker)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial kafka: %w", err)
+	}
+	partitions, err := conn.ReadPartitions(r.dlqTopic)
+	conn.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DLQ partitions: %w", err)
+	}
+
+	writer := &kafka.Writer{
+		Addr:     kafka.TCP(r.broker),
+		Balancer: &kafka.Hash{},
+	}
+	defer writer.Close()
+
+	report := &ReplayReport{DryRun: opts.DryRun, Items: []ReplayItem{}}
+	for _, p := range partitions {
+		if err := r.replayPartition(ctx, p.ID, opts, writer, report); err != nil {
+			return report, err
+		}
+		if opts.Limit > 0 && report.Matched >= opts.Limit {
+			break
+		}
+	}
+
+	slog.Info("DLQ replay finished",
+		slog.Bool("dry_run", report.DryRun),
+		slog.Int("scanned", report.Scanned),
+		slog.Int("matched", report.Matched),
+		slog.Int("replayed", report.Replayed),
+		slog.Int("invalid", report.Invalid),
+		slog.Int("failed", report.Failed),
+	)
+	return report, nil
+}
+
+// replayPartition replays the selected range of a single DLQ partition.
+func (r *Replayer) replayPartition(ctx context.Context, partition int, opts ReplayOptions, writer *kafka.Writer, report *ReplayReport) error {
+	start, end, err := r.partitionRange(ctx, partition, opts)
+	if err != nil {
+		return err
+	}
+	if start >= end {
+		return nil
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   []string{r.broker},
+		Topic:     r.dlqTopic,
+		Partition: partition,
+	})
+	defer reader.Close()
+
+	if err := reader.SetOffset(start); err != nil {
+		return fmt.Errorf("failed to seek DLQ partition %d: %w", partition, err)
+	}
+
+	for offset := start; offset < end; {
+		m, err := reader.ReadMessage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read DLQ partition %d: %w", partition, err)
+		}
+		offset = m.Offset + 1
+		report.Scanned++
+
+		if !opts.Until.IsZero() && m.Time.After(opts.Until) {
+			return nil
+		}
+		if opts.ErrorContains != "" && !strings.Contains(headerValue(m.Headers, HeaderError), opts.ErrorContains) {
+			continue
+		}
+
+		r.replayMessage(ctx, m, opts, writer, report)
+
+		if opts.Limit > 0 && report.Matched >= opts.Limit {
+			return nil
+		}
+	}
+	return nil
+}
+
+// partitionRange returns the offsets [start, end) to scan on a DLQ partition.
+func (r *Replayer) partitionRange(ctx context.Context, partition int, opts ReplayOptions) (int64, int64, error) {
+	conn, err := kafka.DialLeader(ctx, "tcp", r.broker, r.dlqTopic, partition)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to dial DLQ partition %d leader: %w", partition, err)
+	}
+	defer conn.Close()
+
+	first, last, err := conn.ReadOffsets()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read DLQ partition %d offsets: %w", partition, err)
+	}
+
+	start := first
+	switch {
+	case opts.FromOffset >= 0:
+		start = max(first, opts.FromOffset)
+	case !opts.Since.IsZero():
+		if start, err = conn.ReadOffset(opts.Since); err != nil {
+			return 0, 0, fmt.Errorf("failed to resolve DLQ partition %d offset at %s: %w", partition, opts.Since, err)
+		}
+	}
+	return start, last, nil
+}
+
+// replayMessage handles every order found in a single DLQ message.
+func (r *Replayer) replayMessage(ctx context.Context, m kafka.Message, opts ReplayOptions, writer *kafka.Writer, report *ReplayReport) {
+	orders, err := decodeDLQOrders(m.Value)
+	if err != nil {
+		if opts.OrderUID != "" && string(m.Key) != opts.OrderUID {
+			return
+		}
+		report.Matched++
+		report.Invalid++
+		report.Items = append(report.Items, ReplayItem{
+			Partition: m.Partition,
+			Offset:    m.Offset,
+			OrderUID:  string(m.Key),
+			Action:    ReplayActionInvalid,
+			Error:     err.Error(),
+		})
+		return
+	}
+
+	topic := headerValue(m.Headers, HeaderSourceTopic)
+	if topic == "" {
+		topic = r.targetTopic
+	}
+
+	for i := range orders {
+		order := &orders[i]
+		if opts.OrderUID != "" && order.OrderUID != opts.OrderUID {
+			continue
+		}
+		// A batch message can hold more orders than the limit has left.
+		if opts.Limit > 0 && report.Matched >= opts.Limit {
+			return
+		}
+		report.Matched++
+		item := ReplayItem{Partition: m.Partition, Offset: m.Offset, OrderUID: order.OrderUID}
+
+		if err := ValidateOrder(order); err != nil {
+			report.Invalid++
+			item.Action = ReplayActionInvalid
+			item.Error = err.Error()
+			var verr *model.ValidationError
+			if errors.As(err, &verr) {
+				item.Violations = verr.Violations
+			}
+			report.Items = append(report.Items, item)
+			continue
+		}
+		report.Valid++
+
+		if opts.DryRun {
+			item.Action = ReplayActionWouldReplay
+			report.Items = append(report.Items, item)
+			continue
+		}
+
+		if err := r.publish(ctx, writer, topic, m, order); err != nil {
+			slog.Error("failed to replay order", slog.String("order_uid", order.OrderUID), slog.String("error", err.Error()))
+			report.Failed++
+			item.Action = ReplayActionFailed
+			item.Error = err.Error()
+		} else {
+			report.Replayed++
+			item.Action = ReplayActionReplayed
+		}
+		report.Items = append(report.Items, item)
+	}
+}
+
+// publish writes a single order back to the given topic as a one-element batch,
+// which is the format the Consumer expects.
+func (r *Replayer) publish(ctx context.Context, writer *kafka.Writer, topic string, src kafka.Message, order *model.Order) error {
+	value, err := json.Marshal([]*model.Order{order})
+	if err != nil {
+		return err
+	}
+	msg := kafka.Message{
+		Topic: topic,
+		Key:   []byte(order.OrderUID),
+		Value: value,
+		Headers: []kafka.Header{{
+			Key:   HeaderReplayedFrom,
+			Value: []byte(src.Topic + "/" + strconv.Itoa(src.Partition) + "/" + strconv.FormatInt(src.Offset, 10)),
+		}},
+	}
+	// Keep the produce time of the original message for the conflict policy.
+	if sourceTime := headerValue(src.Headers, HeaderSourceTime); sourceTime != "" {
+		msg.Headers = append(msg.Headers, kafka.Header{Key: HeaderSourceTime, Value: []byte(sourceTime)})
+	}
+	injectTrace(ctx, &msg)
+	return writer.WriteMessages(ctx, msg)
+}
+
+// decodeDLQOrders parses a DLQ message value. Current messages hold a single
+// order, older ones may hold the whole original batch.
+func decodeDLQOrders(value []byte) ([]model.Order, error) {
+	var order model.Order
+	if err := json.Unmarshal(value, &order); err == nil {
+		return []model.Order{order}, nil
+	}
+
+	var orders []model.Order
+	if err := json.Unmarshal(value, &orders); err != nil {
+		return nil, fmt.Errorf("unparseable DLQ message: %w", err)
+	}
+	if len(orders) == 0 {
+		return nil, errEmptyBatch
+	}
+	return orders, nil
+}
+
+// headerValue returns the value of the first header with the given key.
+func headerValue(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReplayMessageStopsAtLimit(t *testing.T) {
+	r := NewReplayer("localhost:9092", "orders-dlq", testTopic)
+	m := orderMessage(t, 0, 7, "first", "second", "third")
+
+	tests := []struct {
+		name    string
+		limit   int
+		matched int
+		want    []string
+	}{
+		{name: "no limit", want: []string{"first", "second", "third"}},
+		{name: "limit inside the batch", limit: 2, want: []string{"first", "second"}},
+		{name: "limit left by earlier messages", limit: 3, matched: 2, want: []string{"first"}},
+		{name: "limit already reached", limit: 2, matched: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &ReplayReport{Matched: tt.matched}
+			r.replayMessage(context.Background(), m, ReplayOptions{DryRun: true, Limit: tt.limit}, nil, report)
+
+			if got := report.Matched; got != tt.matched+len(tt.want) {
+				t.Errorf("matched = %d, want %d", got, tt.matched+len(tt.want))
+			}
+			if len(report.Items) != len(tt.want) {
+				t.Fatalf("items = %v, want orders %v", report.Items, tt.want)
+			}
+			for i, item := range report.Items {
+				if item.OrderUID != tt.want[i] {
+					t.Errorf("items[%d] is order %s, want %s", i, item.OrderUID, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"order/internal/config"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Headers attached to messages published to the retry topic.
+const (
+	HeaderRetryAttempt   = "x-retry-attempt"
+	HeaderRetryNotBefore = "x-retry-not-before"
+)
+
+// permanentError marks a handler error that must not be retried.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps a storage error so the consumer sends the order straight to the DLQ instead of retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// backoff computes exponential retry delays with jitter.
+type backoff struct {
+	initial time.Duration
+	max     time.Duration
+}
+
+// delay returns the wait before the given retry attempt (starting at 1).
+// The delay doubles every attempt up to max, and a random jitter of up to
+// half the delay is subtracted so retries from many consumers spread out.
+func (b backoff) delay(attempt int) time.Duration {
+	d := b.initial
+	for i := 1; i < attempt && d < b.max; i++ {
+		d *= 2
+	}
+	if b.max > 0 && d > b.max {
+		d = b.max
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// retryPolicy decides how many times and how long to retry orders that failed to be stored.
+type retryPolicy struct {
+	attempts      int
+	backoff       backoff
+	topicAttempts int
+}
+
+// newRetryPolicy builds a retryPolicy from configuration.
+func newRetryPolicy(cfg config.RetryConfig) retryPolicy {
+	return retryPolicy{
+		attempts:      max(cfg.Attempts, 1),
+		backoff:       backoff{initial: cfg.Backoff, max: cfg.MaxBackoff},
+		topicAttempts: max(cfg.TopicAttempts, 1),
+	}
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryMessage builds a retry topic message for the given attempt.
+func retryMessage(src kafka.Message, groupID string, key, value []byte, reason error, attempt int, notBefore time.Time) kafka.Message {
+	msg := dlqMessage(src, groupID, key, value, reason)
+	msg.Headers = append(msg.Headers,
+		kafka.Header{Key: HeaderRetryAttempt, Value: []byte(strconv.Itoa(attempt))},
+		kafka.Header{Key: HeaderRetryNotBefore, Value: []byte(notBefore.UTC().Format(time.RFC3339Nano))},
+	)
+	return msg
+}
+
+// retryState reads the retry attempt and not-before time from a retry topic message.
+func retryState(m kafka.Message) (attempt int, notBefore time.Time) {
+	attempt, _ = strconv.Atoi(headerValue(m.Headers, HeaderRetryAttempt))
+	notBefore, _ = time.Parse(time.RFC3339Nano, headerValue(m.Headers, HeaderRetryNotBefore))
+	return max(attempt, 1), notBefore
+}
@@ -0,0 +1,350 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"order/internal/model"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// severityOff disables an inherited rule in an override.
+const severityOff model.Severity = "off"
+
+// Index markers of a path step.
+const (
+	noIndex  = -1
+	anyIndex = -2
+)
+
+// RuleSet is a set of validation rules loaded from a YAML or JSON file.
+// They run alongside the struct tag rules of model.Order.
+//
+//	rules:
+//	  - path: items[*].brand
+//	    rule: max
+//	    params: "64"
+//	    severity: reject
+//	overrides:
+//	  - delivery_service: meest
+//	    rules:
+//	      - {path: delivery.zip, rule: len, params: "7", severity: warn}
+type RuleSet struct {
+	Rules     []FieldRule    `yaml:"rules"`
+	Overrides []RuleOverride `yaml:"overrides"`
+}
+
+// FieldRule applies a go-playground validator tag to every value at a JSON path.
+// Path segments are JSON field names; [*] selects all elements of an array.
+type FieldRule struct {
+	Path     string         `yaml:"path"`
+	Rule     string         `yaml:"rule"`
+	Params   string         `yaml:"params"`
+	Severity model.Severity `yaml:"severity"`
+	Code     string         `yaml:"code"`
+	Message  string         `yaml:"message"`
+
+	steps []pathStep
+	tag   string
+}
+
+// RuleOverride changes rules for orders of a delivery service and/or locale.
+// A rule with the same path and rule name replaces the inherited one;
+// severity "off" disables it. Other rules are added.
+type RuleOverride struct {
+	DeliveryService string      `yaml:"delivery_service"`
+	Locale          string      `yaml:"locale"`
+	Rules           []FieldRule `yaml:"rules"`
+}
+
+// pathStep is a resolved path segment: a struct field, optionally indexed.
+type pathStep struct {
+	name  string
+	field int
+	index int
+}
+
+// activeRules holds the rule set used by ValidateOrder, nil if none is configured.
+var activeRules atomic.Pointer[RuleSet]
+
+// SetRules replaces the active rule set. A nil set disables file rules.
+func SetRules(rs *RuleSet) {
+	activeRules.Store(rs)
+}
+
+// LoadRules reads and checks a rules file. Unknown keys, paths that don't
+// exist on model.Order and unknown validator tags are errors.
+func LoadRules(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rs RuleSet
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&rs); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+
+	for i := range rs.Rules {
+		if err := rs.Rules[i].compile(false); err != nil {
+			return nil, fmt.Errorf("invalid rules file %s: rules[%d]: %w", path, i, err)
+		}
+	}
+	for i := range rs.Overrides {
+		o := &rs.Overrides[i]
+		if o.DeliveryService == "" && o.Locale == "" {
+			return nil, fmt.Errorf("invalid rules file %s: overrides[%d]: delivery_service or locale is required", path, i)
+		}
+		for j := range o.Rules {
+			if err := o.Rules[j].compile(true); err != nil {
+				return nil, fmt.Errorf("invalid rules file %s: overrides[%d].rules[%d]: %w", path, i, j, err)
+			}
+		}
+	}
+	return &rs, nil
+}
+
+// compile resolves the rule path against model.Order and checks the validator tag.
+func (r *FieldRule) compile(override bool) error {
+	switch r.Severity {
+	case model.SeverityReject, model.SeverityWarn:
+	case severityOff:
+		if !override {
+			return errors.New(`severity "off" is only allowed in overrides`)
+		}
+	default:
+		return fmt.Errorf("unknown severity %q", r.Severity)
+	}
+
+	if rest, ok := strings.CutPrefix(r.Path, "payment."); ok {
+		slog.Warn("rule path payment.* is deprecated, use payments[*].*", slog.String("path", r.Path))
+		r.Path = "payments[*]." + rest
+	}
+
+	steps, leaf, err := parsePath(r.Path)
+	if err != nil {
+		return err
+	}
+	r.steps = steps
+
+	if r.Rule == "" {
+		return errors.New("rule is required")
+	}
+	r.tag = r.Rule
+	if r.Params != "" {
+		r.tag += "=" + r.Params
+	}
+	return checkTag(r.tag, leaf)
+}
+
+// parsePath resolves a JSON path such as "items[*].price" into struct field
+// steps and returns the type of the value it points to.
+func parsePath(path string) ([]pathStep, reflect.Type, error) {
+	if path == "" {
+		return nil, nil, errors.New("path is required")
+	}
+
+	t := reflect.TypeOf(model.Order{})
+	var steps []pathStep
+	for _, seg := range strings.Split(path, ".") {
+		name, index := seg, noIndex
+		if open := strings.IndexByte(seg, '['); open >= 0 {
+			if !strings.HasSuffix(seg, "]") {
+				return nil, nil, fmt.Errorf("path %q: malformed index in %q", path, seg)
+			}
+			name = seg[:open]
+			switch idx := seg[open+1 : len(seg)-1]; idx {
+			case "*":
+				index = anyIndex
+			default:
+				n, err := strconv.Atoi(idx)
+				if err != nil || n < 0 {
+					return nil, nil, fmt.Errorf("path %q: malformed index in %q", path, seg)
+				}
+				index = n
+			}
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+		case reflect.Slice:
+			return nil, nil, fmt.Errorf("path %q: %q is an array, select elements with [*] or an index", path, steps[len(steps)-1].name)
+		default:
+			return nil, nil, fmt.Errorf("path %q: %q is not an object", path, steps[len(steps)-1].name)
+		}
+		field := jsonField(t, name)
+		if field < 0 {
+			return nil, nil, fmt.Errorf("path %q: unknown field %q", path, name)
+		}
+		t = t.Field(field).Type
+		if index != noIndex {
+			if t.Kind() != reflect.Slice {
+				return nil, nil, fmt.Errorf("path %q: %q is not an array", path, name)
+			}
+			t = t.Elem()
+		}
+		steps = append(steps, pathStep{name: name, field: field, index: index})
+	}
+	return steps, t, nil
+}
+
+// jsonField returns the index of the struct field with the given JSON name, or -1.
+func jsonField(t reflect.Type, name string) int {
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if tag == name && tag != "-" {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkTag runs the tag against a zero value of the field type, turning the
+// validator's panics on unknown tags or malformed params into an error.
+func checkTag(tag string, t reflect.Type) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("invalid rule %q: %v", tag, p)
+		}
+	}()
+	_ = validate.Var(reflect.Zero(t).Interface(), tag)
+	return nil
+}
+
+// Check returns the violations of the rules that apply to the order.
+func (rs *RuleSet) Check(o *model.Order) []model.Violation {
+	var violations []model.Violation
+	root := reflect.ValueOf(o).Elem()
+	for _, r := range rs.rulesFor(o) {
+		walkPath(root, r.steps, "", func(path string, v reflect.Value) {
+			err := validate.Var(v.Interface(), r.tag)
+			var fieldErrs validator.ValidationErrors
+			if !errors.As(err, &fieldErrs) {
+				return
+			}
+			msg := r.Message
+			if msg == "" {
+				msg = describe(fieldErrs[0])
+			}
+			violations = append(violations, model.Violation{
+				Path:     path,
+				Rule:     r.Rule,
+				Code:     r.Code,
+				Severity: r.Severity,
+				Value:    redact(path, fieldErrs[0].Value()),
+				Message:  msg,
+			})
+		})
+	}
+	return violations
+}
+
+// rulesFor merges the base rules with the overrides that match the order.
+func (rs *RuleSet) rulesFor(o *model.Order) []FieldRule {
+	rules := append([]FieldRule(nil), rs.Rules...)
+	for _, ov := range rs.Overrides {
+		if (ov.DeliveryService != "" && ov.DeliveryService != o.DeliveryService) ||
+			(ov.Locale != "" && ov.Locale != o.Locale) {
+			continue
+		}
+		for _, r := range ov.Rules {
+			replaced := false
+			for i := range rules {
+				if rules[i].Path == r.Path && rules[i].Rule == r.Rule {
+					rules[i], replaced = r, true
+				}
+			}
+			if !replaced {
+				rules = append(rules, r)
+			}
+		}
+	}
+
+	active := rules[:0]
+	for _, r := range rules {
+		if r.Severity != severityOff {
+			active = append(active, r)
+		}
+	}
+	return active
+}
+
+// walkPath calls fn with the JSON path and value of every field the steps select.
+func walkPath(v reflect.Value, steps []pathStep, prefix string, fn func(path string, v reflect.Value)) {
+	if len(steps) == 0 {
+		fn(prefix, v)
+		return
+	}
+
+	s := steps[0]
+	path := s.name
+	if prefix != "" {
+		path = prefix + "." + s.name
+	}
+	field := v.Field(s.field)
+
+	switch {
+	case s.index == noIndex:
+		walkPath(field, steps[1:], path, fn)
+	case s.index == anyIndex:
+		for i := 0; i < field.Len(); i++ {
+			walkPath(field.Index(i), steps[1:], fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+	case s.index < field.Len():
+		walkPath(field.Index(s.index), steps[1:], fmt.Sprintf("%s[%d]", path, s.index), fn)
+	}
+}
+
+// WatchRules polls the rules file and reloads it when it changes, until ctx is done.
+// An invalid file is logged and the previous rules stay active.
+// A non-positive interval turns hot reload off.
+func WatchRules(ctx context.Context, path string, interval time.Duration) {
+	if interval <= 0 {
+		slog.Info("validation rules hot reload disabled", slog.String("path", path))
+		return
+	}
+
+	last, _ := os.Stat(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			slog.Error("failed to stat validation rules file", slog.String("path", path), slog.Any("err", err))
+			continue
+		}
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+
+		rs, err := LoadRules(path)
+		if err != nil {
+			slog.Error("validation rules not reloaded, keeping previous rules", slog.Any("err", err))
+			continue
+		}
+		SetRules(rs)
+		slog.Info("validation rules reloaded", slog.String("path", path), slog.Int("rules", len(rs.Rules)), slog.Int("overrides", len(rs.Overrides)))
+	}
+}
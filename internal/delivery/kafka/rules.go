@@ -0,0 +1,157 @@
+package kafka
+
+import (
+	"fmt"
+
+	"order/internal/model"
+)
+
+// Rule is a cross-field consistency check on an order.
+// Check returns a violation with Path, Value and Message set for every
+// problem it finds; the rule fills in its name, code and severity.
+type Rule struct {
+	Name     string
+	Code     string
+	Severity model.Severity
+	Check    func(o *model.Order) []model.Violation
+}
+
+// businessRules are run by ValidateOrder after the struct tag validation passes.
+var businessRules = []Rule{
+	{
+		Name:     "payment_amount_total",
+		Code:     "PAYMENT_AMOUNT_MISMATCH",
+		Severity: model.SeverityReject,
+		Check: func(o *model.Order) []model.Violation {
+			want := 0
+			for _, p := range o.Payments {
+				want += p.GoodsTotal + p.DeliveryCost + p.CustomFee
+			}
+			if paid := o.Paid(); paid != want {
+				return []model.Violation{{
+					Path:    paymentsPath(o, "amount"),
+					Value:   paid,
+					Message: fmt.Sprintf("payments must add up to goods_total + delivery_cost + custom_fee = %d", want),
+				}}
+			}
+			return nil
+		},
+	},
+	{
+		Name:     "items_goods_total",
+		Code:     "GOODS_TOTAL_MISMATCH",
+		Severity: model.SeverityReject,
+		Check: func(o *model.Order) []model.Violation {
+			sum, goods := 0, 0
+			for _, it := range o.Items {
+				sum += it.TotalPrice
+			}
+			for _, p := range o.Payments {
+				goods += p.GoodsTotal
+			}
+			if sum != goods {
+				return []model.Violation{{
+					Path:    paymentsPath(o, "goods_total"),
+					Value:   goods,
+					Message: fmt.Sprintf("must add up to the sum of item total_price = %d", sum),
+				}}
+			}
+			return nil
+		},
+	},
+	{
+		Name:     "payment_currency",
+		Code:     "PAYMENT_CURRENCY_MISMATCH",
+		Severity: model.SeverityReject,
+		Check: func(o *model.Order) []model.Violation {
+			var violations []model.Violation
+			for i, p := range o.Payments {
+				if p.Currency != o.Payments[0].Currency {
+					violations = append(violations, model.Violation{
+						Path:    fmt.Sprintf("payments[%d].currency", i),
+						Value:   p.Currency,
+						Message: fmt.Sprintf("must equal the currency of the first payment %q", o.Payments[0].Currency),
+					})
+				}
+			}
+			return violations
+		},
+	},
+	{
+		Name:     "item_total_price",
+		Code:     "ITEM_TOTAL_PRICE_MISMATCH",
+		Severity: model.SeverityReject,
+		Check: func(o *model.Order) []model.Violation {
+			var violations []model.Violation
+			for i, it := range o.Items {
+				// Allow one unit of rounding in either direction.
+				exact := it.Price * (100 - it.Sale)
+				if diff := it.TotalPrice*100 - exact; diff <= -100 || diff >= 100 {
+					violations = append(violations, model.Violation{
+						Path:    fmt.Sprintf("items[%d].total_price", i),
+						Value:   it.TotalPrice,
+						Message: fmt.Sprintf("must equal price %d minus %d%% sale", it.Price, it.Sale),
+					})
+				}
+			}
+			return violations
+		},
+	},
+	{
+		Name:     "item_track_number",
+		Code:     "ITEM_TRACK_NUMBER_MISMATCH",
+		Severity: model.SeverityReject,
+		Check: func(o *model.Order) []model.Violation {
+			var violations []model.Violation
+			for i, it := range o.Items {
+				if it.TrackNumber != o.TrackNumber {
+					violations = append(violations, model.Violation{
+						Path:    fmt.Sprintf("items[%d].track_number", i),
+						Value:   it.TrackNumber,
+						Message: fmt.Sprintf("must equal order track_number %q", o.TrackNumber),
+					})
+				}
+			}
+			return violations
+		},
+	},
+	{
+		Name:     "payment_transaction",
+		Code:     "TRANSACTION_MISMATCH",
+		Severity: model.SeverityWarn,
+		Check: func(o *model.Order) []model.Violation {
+			for _, p := range o.Payments {
+				if p.Transaction == o.OrderUID {
+					return nil
+				}
+			}
+			return []model.Violation{{
+				Path:    "payments[0].transaction",
+				Value:   o.Payments[0].Transaction,
+				Message: "no payment transaction equals order_uid",
+			}}
+		},
+	},
+}
+
+// paymentsPath returns the path of a payment field, naming the payment if there is only one.
+func paymentsPath(o *model.Order, field string) string {
+	if len(o.Payments) == 1 {
+		return "payments[0]." + field
+	}
+	return "payments[*]." + field
+}
+
+// CheckBusinessRules runs every business rule against the order and returns all violations.
+func CheckBusinessRules(o *model.Order) []model.Violation {
+	var violations []model.Violation
+	for _, rule := range businessRules {
+		for _, v := range rule.Check(o) {
+			v.Rule = rule.Name
+			v.Code = rule.Code
+			v.Severity = rule.Severity
+			violations = append(violations, v)
+		}
+	}
+	return violations
+}
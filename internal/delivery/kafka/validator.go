@@ -3,23 +3,103 @@ package kafka
 import (
 	"errors"
 	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
 
+	"order/internal/metrics"
 	"order/internal/model"
+	"order/internal/refdata"
 
 	"github.com/go-playground/validator/v10"
 )
 
+// redacted replaces the value of personal data fields in violations.
+const redacted = "[REDACTED]"
+
+// piiPaths lists JSON paths (without indexes) whose values must never leave the service.
+var piiPaths = map[string]bool{
+	"customer_id":      true,
+	"delivery.name":    true,
+	"delivery.phone":   true,
+	"delivery.zip":     true,
+	"delivery.city":    true,
+	"delivery.address": true,
+	"delivery.email":   true,
+}
+
+// indexPattern matches slice indexes in a JSON path.
+var indexPattern = regexp.MustCompile(`\[\d+\]`)
+
+// allowedLocales is the set of order locales accepted by the "locale" rule.
+var allowedLocales = map[string]bool{"ru": true, "en": true}
+
+// SetAllowedLocales replaces the set of accepted order locales.
+// Each locale is an ISO 639-1 language code, optionally followed by a region
+// ("en", "en-US"). It must be called before orders are validated.
+func SetAllowedLocales(locales []string) error {
+	if len(locales) == 0 {
+		return errors.New("at least one locale must be allowed")
+	}
+	allowed := make(map[string]bool, len(locales))
+	for _, l := range locales {
+		lang, _, _ := strings.Cut(l, "-")
+		if !refdata.IsLanguage(lang) {
+			return fmt.Errorf("unknown locale %q: %q is not an ISO 639-1 language code", l, lang)
+		}
+		allowed[l] = true
+	}
+	allowedLocales = allowed
+	return nil
+}
+
 var validate *validator.Validate
 
 func init() {
 	validate = validator.New()
 
-	// Register custom validation for locale field ("ru" or "en")
+	// Report field names by their JSON tag so violation paths match the payload
+	validate.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	// Register custom validation for locale field, see SetAllowedLocales
 	_ = validate.RegisterValidation("locale", func(fl validator.FieldLevel) bool {
-		locale := fl.Field().String()
-		return locale == "ru" || locale == "en"
+		return allowedLocales[fl.Field().String()]
+	})
+
+	// Validate timestamps by their text so malformed values are reported as they were received
+	validate.RegisterCustomTypeFunc(func(v reflect.Value) any {
+		return v.Interface().(model.Timestamp).String()
+	}, model.Timestamp{})
+
+	// Register custom validation for RFC 3339 timestamps
+	_ = validate.RegisterValidation("rfc3339", func(fl validator.FieldLevel) bool {
+		_, err := time.Parse(time.RFC3339, fl.Field().String())
+		return err == nil
+	})
+
+	// Register custom validation for ISO 4217 currency codes
+	_ = validate.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+		_, ok := refdata.LookupCurrency(fl.Field().String())
+		return ok
 	})
 
+	// Check the zip code format of regions with a known postal code format.
+	// Other regions only get the generic length checks of the zip tag.
+	validate.RegisterStructValidation(func(sl validator.StructLevel) {
+		d := sl.Current().Interface().(model.Delivery)
+		if f, ok := refdata.LookupPostalFormat(d.Region); ok && d.Zip != "" && !f.Match(d.Zip) {
+			sl.ReportError(d.Zip, "zip", "Zip", "postcode", f.Country)
+		}
+	}, model.Delivery{})
+
 	// Register custom validation for phone numbers in E.164 format
 	_ = validate.RegisterValidation("e164", func(fl validator.FieldLevel) bool {
 		phone := fl.Field().String()
@@ -38,24 +118,154 @@ func init() {
 	})
 }
 
-// ValidateStruct validates any struct using the registered rules
+// ValidateStruct validates any struct using the registered rules.
+// Failures are returned as a *model.ValidationError.
 func ValidateStruct(s any) error {
 	err := validate.Struct(s)
 	if err == nil {
 		return nil
 	}
 
-	var errs string
-	for _, e := range err.(validator.ValidationErrors) {
-		errs += fmt.Sprintf("Field '%s' is invalid: rules '%s'\n",
-			e.Field(), e.ActualTag())
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+
+	violations := make([]model.Violation, 0, len(fieldErrs))
+	for _, e := range fieldErrs {
+		path := jsonPath(e.Namespace())
+		value := redact(path, e.Value())
+		if e.Kind() == reflect.Slice {
+			// Rules on whole arrays (min, unique) don't echo all of their elements.
+			value = nil
+		}
+		violations = append(violations, model.Violation{
+			Path:     path,
+			Rule:     e.ActualTag(),
+			Severity: model.SeverityReject,
+			Value:    value,
+			Message:  describe(e),
+		})
 	}
-	return errors.New(errs)
+	return &model.ValidationError{Violations: violations}
 }
 
-// ValidateOrder validates an Order struct
+// jsonPath strips the root struct name from a validator namespace,
+// turning "Order.items[2].price" into "items[2].price".
+func jsonPath(namespace string) string {
+	if _, rest, ok := strings.Cut(namespace, "."); ok {
+		return rest
+	}
+	return namespace
+}
+
+// redact hides the value of personal data fields.
+func redact(path string, value any) any {
+	if piiPaths[indexPattern.ReplaceAllString(path, "")] {
+		return redacted
+	}
+	return value
+}
+
+// describe builds a human-readable message for a failed validation tag.
+func describe(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		if e.Kind() == reflect.Slice {
+			if e.Param() == "1" {
+				return "must not be empty"
+			}
+			return fmt.Sprintf("must have at least %s elements", e.Param())
+		}
+		return fmt.Sprintf("must be at least %s characters long", e.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters long", e.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", e.Param())
+	case "gte":
+		return fmt.Sprintf("must be greater than or equal to %s", e.Param())
+	case "lte":
+		return fmt.Sprintf("must be less than or equal to %s", e.Param())
+	case "len":
+		return fmt.Sprintf("must be exactly %s characters long", e.Param())
+	case "unique":
+		if e.Param() == "" {
+			return "must not contain duplicates"
+		}
+		return fmt.Sprintf("must not repeat the same %s", strings.ToLower(e.Param()))
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", e.Param())
+	case "startswith":
+		return fmt.Sprintf("must start with %q", e.Param())
+	case "email":
+		return "must be a valid email address"
+	case "e164":
+		return "must be a phone number in E.164 format"
+	case "numeric":
+		return "must be numeric"
+	case "ascii":
+		return "must contain only ASCII characters"
+	case "alphanumunicode":
+		return "must contain only letters and digits"
+	case "locale":
+		return "must be a supported locale"
+	case "rfc3339":
+		return "must be an RFC 3339 timestamp, e.g. 2021-11-26T06:22:19Z"
+	case "currency":
+		return "must be an ISO 4217 currency code"
+	case "postcode":
+		return fmt.Sprintf("must be a valid postal code for %s", e.Param())
+	default:
+		return fmt.Sprintf("failed the '%s' rule", e.Tag())
+	}
+}
+
+// ValidateOrder validates an Order struct together with the rules file, if one
+// is configured, and then its business rules.
+// Rules with warn severity are only logged; rules with reject severity fail validation.
 func ValidateOrder(o *model.Order) error {
-	return ValidateStruct(o)
+	var violations []model.Violation
+	if err := ValidateStruct(o); err != nil {
+		var verr *model.ValidationError
+		if !errors.As(err, &verr) {
+			return err
+		}
+		violations = verr.Violations
+	}
+	if rs := activeRules.Load(); rs != nil {
+		violations = append(violations, rs.Check(o)...)
+	}
+	if err := rejectViolations(o, violations); err != nil {
+		return err
+	}
+
+	return rejectViolations(o, CheckBusinessRules(o))
+}
+
+// rejectViolations logs warn-level violations and returns the reject-level
+// ones as a *model.ValidationError, or nil if there are none.
+func rejectViolations(o *model.Order, violations []model.Violation) error {
+	var rejected []model.Violation
+	for _, v := range violations {
+		metrics.RuleViolations.WithLabelValues(v.Rule, string(v.Severity)).Inc()
+		if v.Severity == model.SeverityWarn {
+			slog.Warn("order validation warning",
+				slog.String("order_uid", o.OrderUID),
+				slog.String("path", v.Path),
+				slog.String("rule", v.Rule),
+				slog.String("code", v.Code),
+				slog.String("message", v.Message),
+			)
+			continue
+		}
+		rejected = append(rejected, v)
+	}
+	if len(rejected) > 0 {
+		return &model.ValidationError{Violations: rejected}
+	}
+	return nil
 }
 
 // ValidateDelivery validates a Delivery struct
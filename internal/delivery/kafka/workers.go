@@ -0,0 +1,255 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"order/internal/config"
+	"order/internal/metrics"
+	"order/internal/tracing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Ordering modes supported by the worker pool.
+const (
+	OrderingPartition = "partition"
+	OrderingKey       = "key"
+)
+
+// commitTimeout bounds a single offset commit, including the final ones during shutdown.
+const commitTimeout = 5 * time.Second
+
+// workerPool settings resolved from configuration.
+type workerPool struct {
+	workers     int
+	maxInFlight int
+	byKey       bool
+}
+
+// newWorkerPool builds workerPool settings from configuration.
+func newWorkerPool(cfg config.WorkersConfig) workerPool {
+	if cfg.Ordering != OrderingPartition && cfg.Ordering != OrderingKey {
+		slog.Warn("Unknown KAFKA_ORDERING, defaulting to partition", "value", cfg.Ordering)
+	}
+	return workerPool{
+		workers:     max(cfg.Count, 1),
+		maxInFlight: max(cfg.MaxInFlight, 1),
+		byKey:       cfg.Ordering == OrderingKey,
+	}
+}
+
+// route picks the worker for a message. Messages of the same partition (or
+// the same key in key ordering) always go to the same worker, which keeps them in order.
+func (p workerPool) route(m kafka.Message) int {
+	h := fnv.New32a()
+	if p.byKey && len(m.Key) > 0 {
+		h.Write(m.Key)
+	} else {
+		h.Write([]byte{byte(m.Partition >> 24), byte(m.Partition >> 16), byte(m.Partition >> 8), byte(m.Partition)})
+	}
+	return int(h.Sum32() % uint32(p.workers))
+}
+
+// partitionKey identifies a topic partition.
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+// offsetTracker remembers fetched messages per partition and reports the
+// highest offset that can be committed, i.e. the end of the contiguous run of
+// processed messages. This keeps commits in order even when messages of one
+// partition finish out of order.
+type offsetTracker struct {
+	mu      sync.Mutex
+	pending map[partitionKey][]kafka.Message
+	done    map[partitionKey]map[int64]bool
+}
+
+// newOffsetTracker creates an empty offsetTracker.
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		pending: map[partitionKey][]kafka.Message{},
+		done:    map[partitionKey]map[int64]bool{},
+	}
+}
+
+// add registers a fetched message. Messages must be added in fetch order.
+func (t *offsetTracker) add(m kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := partitionKey{m.Topic, m.Partition}
+	t.pending[key] = append(t.pending[key], m)
+}
+
+// markDone marks a message as processed and returns the message to commit,
+// if the contiguous processed run moved forward.
+func (t *offsetTracker) markDone(m kafka.Message) (kafka.Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := partitionKey{m.Topic, m.Partition}
+	if t.done[key] == nil {
+		t.done[key] = map[int64]bool{}
+	}
+	t.done[key][m.Offset] = true
+
+	var commit kafka.Message
+	var ok bool
+	queue := t.pending[key]
+	for len(queue) > 0 && t.done[key][queue[0].Offset] {
+		commit, ok = queue[0], true
+		delete(t.done[key], queue[0].Offset)
+		queue = queue[1:]
+	}
+	t.pending[key] = queue
+	return commit, ok
+}
+
+// committer commits offsets from a single goroutine so commits of one
+// partition never go backwards.
+func committer(ctx context.Context, reader messageReader, commits <-chan kafka.Message) {
+	committed := map[partitionKey]int64{}
+	for m := range commits {
+		key := partitionKey{m.Topic, m.Partition}
+		if last, ok := committed[key]; ok && m.Offset <= last {
+			continue
+		}
+
+		commitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commitTimeout)
+		err := reader.CommitMessages(commitCtx, m)
+		cancel()
+		if err != nil {
+			metrics.KafkaCommitFailures.WithLabelValues(m.Topic).Inc()
+			slog.Error("failed to commit message",
+				slog.String("topic", m.Topic),
+				slog.Int("partition", m.Partition),
+				slog.Int64("offset", m.Offset),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		committed[key] = m.Offset
+	}
+}
+
+// consume fetches messages from reader until ctx is done and processes them
+// on the worker pool. Each message is committed, in order per partition, once
+// it and every earlier message of its partition are fully processed.
+// retryAttempt returns the retry topic attempt a message belongs to, 0 for the
+// main topic; it runs on the worker, so any wait it does only blocks that worker.
+func (c *Consumer) consume(ctx context.Context, reader messageReader, handle HandleFunc, retryAttempt func(kafka.Message) int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		fatalOnce sync.Once
+		fatalErr  error
+	)
+	fail := func(err error) {
+		fatalOnce.Do(func() {
+			fatalErr = err
+			cancel()
+		})
+	}
+
+	tracker := newOffsetTracker()
+	inFlight := make(chan struct{}, c.pool.maxInFlight)
+	commits := make(chan kafka.Message, c.pool.maxInFlight)
+
+	var committerDone sync.WaitGroup
+	committerDone.Add(1)
+	go func() {
+		defer committerDone.Done()
+		committer(ctx, reader, commits)
+	}()
+
+	queues := make([]chan kafka.Message, c.pool.workers)
+	var workers sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan kafka.Message, c.pool.maxInFlight)
+		workers.Add(1)
+		go func(queue <-chan kafka.Message) {
+			defer workers.Done()
+			for m := range queue {
+				c.work(ctx, m, handle, retryAttempt, tracker, commits, fail)
+				<-inFlight
+			}
+		}(queues[i])
+	}
+
+	defer func() {
+		for _, q := range queues {
+			close(q)
+		}
+		workers.Wait()
+		close(commits)
+		committerDone.Wait()
+	}()
+
+	for {
+		select {
+		case inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return fatalErr
+		}
+
+		m, err := reader.FetchMessage(ctx)
+		if err != nil {
+			<-inFlight
+			if ctx.Err() != nil {
+				return fatalErr
+			}
+			slog.Error("error reading message", slog.String("error", err.Error()))
+			continue
+		}
+
+		metrics.KafkaMessagesConsumed.WithLabelValues(m.Topic).Inc()
+		metrics.KafkaConsumerLag.WithLabelValues(m.Topic, strconv.Itoa(m.Partition)).Set(float64(max(m.HighWaterMark-m.Offset-1, 0)))
+
+		tracker.add(m)
+		queues[c.pool.route(m)] <- m
+	}
+}
+
+// work processes a single message on a worker and queues its commit.
+func (c *Consumer) work(ctx context.Context, m kafka.Message, handle HandleFunc, retryAttempt func(kafka.Message) int, tracker *offsetTracker, commits chan<- kafka.Message, fail func(error)) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	attempt := retryAttempt(m)
+	if ctx.Err() != nil {
+		return
+	}
+
+	spanCtx, span := startProcessSpan(ctx, m)
+	err := c.process(spanCtx, m, handle, attempt)
+	if err != nil {
+		tracing.RecordError(span, err)
+	}
+	span.End()
+
+	if err != nil {
+		if ctx.Err() != nil {
+			slog.Warn("consumer stopped before message was resolved, it will be redelivered",
+				slog.String("topic", m.Topic),
+				slog.Int("partition", m.Partition),
+				slog.Int64("offset", m.Offset),
+			)
+			return
+		}
+		fail(err)
+		return
+	}
+
+	if commit, ok := tracker.markDone(m); ok {
+		commits <- commit
+	}
+}
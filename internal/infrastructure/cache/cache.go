@@ -1,12 +1,16 @@
 package cache
 
 import (
+	"context"
 	"log/slog"
 	"sync"
 
+	"order/internal/metrics"
 	"order/internal/model"
+	"order/internal/tracing"
 
 	lru "github.com/hashicorp/golang-lru/v2"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // OrderCache is a thread-safe LRU cache for storing orders
@@ -25,31 +29,56 @@ func NewOrderCache(size int) (*OrderCache, error) {
 }
 
 // Get returns the order by UID if it exists in the cache
-func (oc *OrderCache) Get(uid string) (*model.Order, bool) {
+func (oc *OrderCache) Get(ctx context.Context, uid string) (*model.Order, bool) {
+	_, span := tracing.Tracer().Start(ctx, "cache.Get")
+	defer span.End()
+	span.SetAttributes(tracing.OrderUID.String(uid))
+
 	oc.mu.RLock()
 	defer oc.mu.RUnlock()
 
 	order, ok := oc.cache.Get(uid)
+	span.SetAttributes(attribute.Bool("cache.hit", ok))
 	if !ok {
+		metrics.CacheMisses.Inc()
 		return nil, false
 	}
+	metrics.CacheHits.Inc()
 	return order, true
 }
 
-// Set adds or updates an order in the cache
-func (oc *OrderCache) Set(uid string, order *model.Order) {
+// Set adds or updates an order in the cache.
+// An order with a lower Version than the cached one is ignored, so concurrent
+// writers finishing out of order can't leave an outdated order cached.
+func (oc *OrderCache) Set(ctx context.Context, uid string, order *model.Order) {
+	_, span := tracing.Tracer().Start(ctx, "cache.Set")
+	defer span.End()
+	span.SetAttributes(tracing.OrderUID.String(uid))
+
 	oc.mu.Lock()
 	defer oc.mu.Unlock()
 
-	oc.cache.Add(uid, order)
+	if cached, ok := oc.cache.Peek(uid); ok && cached.Version > order.Version {
+		slog.Debug("cache set skipped, newer version cached", slog.String("uid", uid))
+		return
+	}
+	if evicted := oc.cache.Add(uid, order); evicted {
+		metrics.CacheEvictions.Inc()
+	}
+	metrics.CacheSize.Set(float64(oc.cache.Len()))
 	slog.Debug("cache set", slog.String("uid", uid))
 }
 
 // Delete removes an order from the cache by UID
-func (oc *OrderCache) Delete(uid string) {
+func (oc *OrderCache) Delete(ctx context.Context, uid string) {
+	_, span := tracing.Tracer().Start(ctx, "cache.Delete")
+	defer span.End()
+	span.SetAttributes(tracing.OrderUID.String(uid))
+
 	oc.mu.Lock()
 	defer oc.mu.Unlock()
 
 	oc.cache.Remove(uid)
+	metrics.CacheSize.Set(float64(oc.cache.Len()))
 	slog.Debug("cache delete", slog.String("uid", uid))
 }
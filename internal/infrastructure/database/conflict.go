@@ -0,0 +1,94 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"order/internal/model"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ConflictPolicy decides what AddOrder does with an update whose source time
+// is older than that of the stored order.
+type ConflictPolicy string
+
+// Supported conflict policies.
+const (
+	// ConflictReject fails the stale update with ErrOrderConflict.
+	ConflictReject ConflictPolicy = "reject"
+	// ConflictKeepNewest skips the stale update with ErrStaleOrder.
+	ConflictKeepNewest ConflictPolicy = "newest"
+	// ConflictOverwrite stores every update, the last write wins.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+)
+
+// ErrOrderConflict is returned by AddOrder under ConflictReject when the update is older than the stored order.
+var ErrOrderConflict = errors.New("order update is older than the stored order")
+
+// ErrStaleOrder is returned by AddOrder under ConflictKeepNewest when the update
+// is older than the stored order and was skipped.
+var ErrStaleOrder = errors.New("stale order update skipped")
+
+// errVersionConflict means another writer changed the order between reading and writing it.
+var errVersionConflict = errors.New("order was modified concurrently")
+
+// maxConflictAttempts bounds how many times a writer re-reads the order after a concurrent write.
+const maxConflictAttempts = 5
+
+// ordersPrimaryKey is the constraint violated when two writers insert the same new order.
+const ordersPrimaryKey = "orders_pkey"
+
+// ParseConflictPolicy parses "reject", "newest" or "overwrite".
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case ConflictReject, ConflictKeepNewest, ConflictOverwrite:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q, want reject, newest or overwrite", s)
+}
+
+// checkConflict applies the policy to an update of the stored order from source.
+// Updates with an unknown source time, or of an order whose source time is unknown,
+// are never stale.
+func (p ConflictPolicy) checkConflict(stored *model.Order, source model.Source) error {
+	if p == ConflictOverwrite || source.Time.IsZero() || !stored.SourceTime.Parsed() {
+		return nil
+	}
+	if !source.Time.Before(stored.SourceTime.Time) {
+		return nil
+	}
+	if p == ConflictReject {
+		return fmt.Errorf("%w: update from %s emitted at %s, stored version %d at %s",
+			ErrOrderConflict, source.Name, source.Time.UTC().Format(time.RFC3339Nano),
+			stored.Version, stored.SourceTime.UTC().Format(time.RFC3339Nano))
+	}
+	return ErrStaleOrder
+}
+
+// isDuplicateOrderInsert reports whether err is the primary key violation of
+// inserting an order that another writer has just inserted.
+func isDuplicateOrderInsert(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == ordersPrimaryKey
+}
+
+// retryOnVersionConflict runs attempt until it returns something other than
+// errVersionConflict, at most maxConflictAttempts times. Each attempt must
+// re-read the order, so it sees the write it lost to.
+func retryOnVersionConflict[T any](ctx context.Context, op string, attempt func() (T, error)) (T, error) {
+	for n := 1; ; n++ {
+		v, err := attempt()
+		if !errors.Is(err, errVersionConflict) || n >= maxConflictAttempts {
+			return v, err
+		}
+		slog.DebugContext(ctx, "order modified concurrently, retrying",
+			slog.String("op", op),
+			slog.Int("attempt", n),
+		)
+	}
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order/internal/model"
+)
+
+func TestRetryOnVersionConflict(t *testing.T) {
+	errBroken := errors.New("connection refused")
+
+	tests := []struct {
+		name      string
+		conflicts int
+		err       error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "no conflict", wantCalls: 1},
+		{name: "conflicts then success", conflicts: 2, wantCalls: 3},
+		{name: "conflicts exhaust the attempts", conflicts: maxConflictAttempts, wantCalls: maxConflictAttempts, wantErr: errVersionConflict},
+		{name: "other errors are not retried", err: errBroken, wantCalls: 1, wantErr: errBroken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			got, err := retryOnVersionConflict(context.Background(), "test", func() (int, error) {
+				calls++
+				if calls <= tt.conflicts {
+					return 0, errVersionConflict
+				}
+				return calls, tt.err
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("attempts = %d, want %d", calls, tt.wantCalls)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && got != calls {
+				t.Errorf("result = %d, want the one of attempt %d", got, calls)
+			}
+		})
+	}
+}
+
+func TestCheckConflict(t *testing.T) {
+	stored := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	order := &model.Order{OrderUID: "b563feb7b2b84b6test", Version: 3, SourceTime: model.NewTimestamp(stored)}
+
+	tests := []struct {
+		policy ConflictPolicy
+		source time.Time
+		want   error
+	}{
+		{ConflictReject, stored.Add(-time.Minute), ErrOrderConflict},
+		{ConflictReject, stored, nil},
+		{ConflictReject, stored.Add(time.Minute), nil},
+		{ConflictReject, time.Time{}, nil},
+		{ConflictKeepNewest, stored.Add(-time.Minute), ErrStaleOrder},
+		{ConflictKeepNewest, stored.Add(time.Minute), nil},
+		{ConflictOverwrite, stored.Add(-time.Minute), nil},
+	}
+	for _, tt := range tests {
+		err := tt.policy.checkConflict(order, model.Source{Name: "test", Time: tt.source})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s policy, source %s: err = %v, want %v", tt.policy, tt.source, err, tt.want)
+		}
+	}
+
+	// Orders stored before source times were recorded are never stale.
+	unknown := &model.Order{OrderUID: order.OrderUID, Version: 1}
+	if err := ConflictReject.checkConflict(unknown, model.Source{Time: stored}); err != nil {
+		t.Errorf("order without source time: err = %v, want nil", err)
+	}
+}
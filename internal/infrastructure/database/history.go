@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"order/internal/metrics"
+	"order/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// appendVersion stores a snapshot of the order under its current version.
+// It must run in the transaction that saves the order.
+func appendVersion(tx *gorm.DB, order *model.Order, source model.Source) error {
+	version := model.OrderVersion{
+		OrderUID:   order.OrderUID,
+		Version:    order.Version,
+		Source:     source.Name,
+		SourceTime: model.NewTimestamp(source.Time),
+		Snapshot:   *order,
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		return err
+	}
+	slog.Debug("order version recorded",
+		slog.String("uid", order.OrderUID),
+		slog.Int("version", version.Version),
+		slog.String("source", source.Name),
+	)
+	return nil
+}
+
+// GetOrderHistory returns every stored version of an order, oldest first.
+// It returns an empty slice if the order has no history.
+func (r *Repository) GetOrderHistory(ctx context.Context, uid string) ([]model.OrderVersion, error) {
+	defer metrics.ObserveQuery("order_history", time.Now())
+
+	var versions []model.OrderVersion
+	err := r.db.WithContext(ctx).
+		Where("order_uid = ?", uid).
+		Order("version").
+		Find(&versions).Error
+	if err != nil {
+		slog.Error("db error on order history", slog.String("uid", uid), slog.Any("err", err))
+		return nil, err
+	}
+	return versions, nil
+}
+
+// GetOrderVersion returns a single version of an order, or nil, nil if it does not exist.
+func (r *Repository) GetOrderVersion(ctx context.Context, uid string, version int) (*model.OrderVersion, error) {
+	defer metrics.ObserveQuery("order_version", time.Now())
+
+	var versions []model.OrderVersion
+	err := r.db.WithContext(ctx).
+		Where("order_uid = ? AND version = ?", uid, version).
+		Limit(1).
+		Find(&versions).Error
+	if err != nil {
+		slog.Error("db error on order version", slog.String("uid", uid), slog.Int("version", version), slog.Any("err", err))
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, nil
+	}
+	return &versions[0], nil
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"order/internal/metrics"
+	"order/internal/model"
+)
+
+const (
+	// DefaultListLimit is the page size used when the caller does not provide one.
+	DefaultListLimit = 20
+	// MaxListLimit is the largest page size a caller may request.
+	MaxListLimit = 100
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrInvalidSort is returned when an unsupported sort key is requested.
+var ErrInvalidSort = errors.New("invalid sort")
+
+// noDate is the sort value of orders whose date_created could not be migrated (NULL),
+// so that they sort before all dated orders and keyset comparisons stay defined.
+const noDate = "-infinity"
+
+// sortColumns maps public sort keys to their database columns.
+var sortColumns = map[string]string{
+	"date_created": "COALESCE(orders.date_created, '" + noDate + "')",
+	"order_uid":    "orders.order_uid",
+	"customer_id":  "orders.customer_id",
+}
+
+// OrderFilter narrows down the set of orders returned by ListOrders.
+// Empty fields and zero times are ignored.
+type OrderFilter struct {
+	CustomerID      string
+	DeliveryService string
+	PaymentProvider string
+	PaymentCurrency string
+	Locale          string
+	DateFrom        time.Time
+	DateTo          time.Time
+}
+
+// ListOrdersParams holds filters, sorting and pagination for ListOrders.
+// Sort is a sort key optionally prefixed with "-" for descending order.
+type ListOrdersParams struct {
+	Filter OrderFilter
+	Sort   string
+	Limit  int
+	Cursor string
+}
+
+// OrderPage is a single page of orders with the cursor to the next one.
+type OrderPage struct {
+	Orders     []model.Order `json:"orders"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// cursor points at the last row of the previous page.
+type cursor struct {
+	Value string `json:"v"`
+	UID   string `json:"uid"`
+}
+
+// encodeCursor serializes a cursor into an opaque URL-safe string.
+func encodeCursor(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor parses a cursor produced by encodeCursor for the given sort key.
+// A date_created cursor must hold an RFC 3339 time or noDate, so a hand-made or
+// stale cursor is rejected instead of failing in the database.
+func decodeCursor(s, key string) (cursor, error) {
+	var c cursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &c); err != nil || c.UID == "" {
+		return c, ErrInvalidCursor
+	}
+	if key == "date_created" && c.Value != noDate {
+		if _, err := time.Parse(time.RFC3339Nano, c.Value); err != nil {
+			return c, ErrInvalidCursor
+		}
+	}
+	return c, nil
+}
+
+// parseSort resolves a sort key into its column and direction.
+func parseSort(sort string) (key, column string, desc bool, err error) {
+	if sort == "" {
+		sort = "-date_created"
+	}
+	key = sort
+	if key[0] == '-' {
+		desc = true
+		key = key[1:]
+	}
+	column, ok := sortColumns[key]
+	if !ok {
+		return "", "", false, fmt.Errorf("%w: %q", ErrInvalidSort, sort)
+	}
+	return key, column, desc, nil
+}
+
+// sortValue returns the value of the sort key for the given order.
+func sortValue(o *model.Order, key string) string {
+	switch key {
+	case "order_uid":
+		return o.OrderUID
+	case "customer_id":
+		return o.CustomerID
+	default:
+		if !o.DateCreated.Parsed() {
+			return noDate
+		}
+		return o.DateCreated.Format(time.RFC3339Nano)
+	}
+}
+
+// ListOrders returns a page of orders matching the filter using keyset pagination.
+// Delivery, Payments, Items and Refunds are preloaded in batches, one query per association.
+func (r *Repository) ListOrders(ctx context.Context, params ListOrdersParams) (*OrderPage, error) {
+	defer metrics.ObserveQuery("list_orders", time.Now())
+
+	key, column, desc, err := parseSort(params.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+
+	f := params.Filter
+	query := r.db.WithContext(ctx).Model(&model.Order{}).Select("orders.*")
+
+	if f.PaymentProvider != "" || f.PaymentCurrency != "" {
+		// An order matches if one of its payments does; EXISTS keeps orders with several payments from repeating.
+		payments := r.db.Model(&model.Payment{}).Select("1").Where("payments.order_uid = orders.order_uid")
+		if f.PaymentProvider != "" {
+			payments = payments.Where("payments.provider = ?", f.PaymentProvider)
+		}
+		if f.PaymentCurrency != "" {
+			payments = payments.Where("payments.currency = ?", f.PaymentCurrency)
+		}
+		query = query.Where("EXISTS (?)", payments)
+	}
+	if f.CustomerID != "" {
+		query = query.Where("orders.customer_id = ?", f.CustomerID)
+	}
+	if f.DeliveryService != "" {
+		query = query.Where("orders.delivery_service = ?", f.DeliveryService)
+	}
+	if f.Locale != "" {
+		query = query.Where("orders.locale = ?", f.Locale)
+	}
+	if !f.DateFrom.IsZero() {
+		query = query.Where("orders.date_created >= ?", f.DateFrom)
+	}
+	if !f.DateTo.IsZero() {
+		query = query.Where("orders.date_created <= ?", f.DateTo)
+	}
+
+	direction, op := "ASC", ">"
+	if desc {
+		direction, op = "DESC", "<"
+	}
+
+	if params.Cursor != "" {
+		c, err := decodeCursor(params.Cursor, key)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(
+			fmt.Sprintf("(%s, orders.order_uid) %s (?, ?)", column, op),
+			c.Value, c.UID,
+		)
+	}
+
+	var orders []model.Order
+	err = withAssociations(query).
+		Order(fmt.Sprintf("%s %s, orders.order_uid %s", column, direction, direction)).
+		Limit(limit + 1).
+		Find(&orders).Error
+	if err != nil {
+		slog.Error("db error on list orders", slog.Any("err", err))
+		return nil, err
+	}
+
+	for i := range orders {
+		orders[i].UpdateNetAmount()
+	}
+
+	page := &OrderPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		last := &page.Orders[limit-1]
+		page.NextCursor = encodeCursor(cursor{Value: sortValue(last, key), UID: last.OrderUID})
+	}
+
+	slog.Debug("orders listed", slog.Int("count", len(page.Orders)), slog.Bool("has_more", page.NextCursor != ""))
+	return page, nil
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"order/internal/metrics"
+	"order/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// GetOrderByTrackNumber resolves an order by its track number.
+// If several orders share a track number, the most recent one is returned.
+func (r *Repository) GetOrderByTrackNumber(ctx context.Context, trackNumber string) (*model.Order, error) {
+	return r.lookupOrder(ctx, "track_number", trackNumber, "orders.order_uid",
+		r.db.WithContext(ctx).Model(&model.Order{}).
+			Where("orders.track_number = ?", trackNumber).
+			Order("orders.date_created DESC NULLS LAST"),
+	)
+}
+
+// GetOrderByTransaction resolves an order by its payment transaction ID.
+func (r *Repository) GetOrderByTransaction(ctx context.Context, transaction string) (*model.Order, error) {
+	return r.lookupOrder(ctx, "transaction", transaction, "orders.order_uid", r.transactionQuery(ctx, transaction))
+}
+
+// transactionQuery selects the orders paid by the given transaction.
+func (r *Repository) transactionQuery(ctx context.Context, transaction string) *gorm.DB {
+	return r.db.WithContext(ctx).Model(&model.Order{}).
+		Joins("JOIN payments ON payments.order_uid = orders.order_uid").
+		Where("payments.transaction = ?", transaction)
+}
+
+// GetOrderByItemRID resolves the order that contains the item with the given RID.
+// RIDs are only unique within an order; if several orders contain the RID,
+// the most recent one is returned.
+func (r *Repository) GetOrderByItemRID(ctx context.Context, rid string) (*model.Order, error) {
+	return r.lookupOrder(ctx, "rid", rid, "orders.order_uid",
+		r.db.WithContext(ctx).Model(&model.Order{}).
+			Joins("JOIN items ON items.order_uid = orders.order_uid").
+			Where("items.rid = ?", rid).
+			Order("orders.date_created DESC NULLS LAST").
+			Order("orders.order_uid"),
+	)
+}
+
+// lookupOrder plucks a single order UID from the given query and loads that order through the cache.
+// It returns nil, nil if no order matches.
+func (r *Repository) lookupOrder(ctx context.Context, field, value, uidColumn string, query *gorm.DB) (*model.Order, error) {
+	start := time.Now()
+	var uids []string
+	err := query.Limit(1).Pluck(uidColumn, &uids).Error
+	metrics.ObserveQuery("lookup_by_"+field, start)
+	if err != nil {
+		slog.Error("db error on order lookup", slog.String(field, value), slog.Any("err", err))
+		return nil, err
+	}
+	if len(uids) == 0 {
+		slog.Warn("order not found by lookup", slog.String(field, value))
+		return nil, nil
+	}
+	return r.GetOrderWithCache(ctx, uids[0])
+}
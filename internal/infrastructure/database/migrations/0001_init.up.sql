@@ -0,0 +1,74 @@
+-- Baseline schema. Statements are idempotent so databases previously
+-- created by GORM AutoMigrate can adopt versioned migrations.
+
+CREATE TABLE IF NOT EXISTS deliveries (
+    id      bigserial PRIMARY KEY,
+    name    varchar(255) NOT NULL,
+    phone   varchar(50)  NOT NULL,
+    zip     varchar(50)  NOT NULL,
+    city    varchar(255) NOT NULL,
+    address varchar(255) NOT NULL,
+    region  varchar(255) NOT NULL,
+    email   varchar(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS payments (
+    id            bigserial PRIMARY KEY,
+    transaction   varchar(255) NOT NULL,
+    request_id    varchar(255),
+    currency      varchar(10)  NOT NULL,
+    provider      varchar(50)  NOT NULL,
+    amount        bigint       NOT NULL,
+    payment_dt    bigint       NOT NULL,
+    bank          varchar(50)  NOT NULL,
+    delivery_cost bigint       NOT NULL,
+    goods_total   bigint       NOT NULL,
+    custom_fee    bigint       NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    order_uid          varchar(255) PRIMARY KEY,
+    track_number       varchar(255) NOT NULL,
+    entry              varchar(50)  NOT NULL,
+    locale             varchar(10)  NOT NULL,
+    internal_signature varchar(255),
+    customer_id        varchar(255) NOT NULL,
+    delivery_service   varchar(255) NOT NULL,
+    shardkey           varchar(50)  NOT NULL,
+    sm_id              bigint       NOT NULL,
+    date_created       varchar(50)  NOT NULL,
+    oof_shard          varchar(50)  NOT NULL,
+    delivery_id        bigint,
+    payment_id         bigint,
+    CONSTRAINT fk_orders_delivery FOREIGN KEY (delivery_id) REFERENCES deliveries (id),
+    CONSTRAINT fk_orders_payment  FOREIGN KEY (payment_id)  REFERENCES payments (id)
+);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS content_hash varchar(64);
+
+CREATE TABLE IF NOT EXISTS items (
+    id           bigserial PRIMARY KEY,
+    rid          varchar(255),
+    chrt_id      bigint       NOT NULL,
+    track_number varchar(255) NOT NULL,
+    price        bigint       NOT NULL,
+    name         varchar(255) NOT NULL,
+    sale         bigint       NOT NULL,
+    size         varchar(50),
+    total_price  bigint       NOT NULL,
+    nm_id        bigint       NOT NULL,
+    brand        varchar(255) NOT NULL,
+    status       bigint       NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    order_order_uid varchar(255),
+    item_id         bigint,
+    PRIMARY KEY (order_order_uid, item_id),
+    CONSTRAINT fk_order_items_order FOREIGN KEY (order_order_uid) REFERENCES orders (order_uid) ON DELETE CASCADE,
+    CONSTRAINT fk_order_items_item  FOREIGN KEY (item_id)         REFERENCES items (id)         ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_track_number   ON orders (track_number);
+CREATE INDEX IF NOT EXISTS idx_payments_transaction ON payments (transaction);
+CREATE INDEX IF NOT EXISTS idx_items_rid             ON items (rid);
@@ -0,0 +1,17 @@
+DROP INDEX IF EXISTS idx_orders_date_created;
+
+ALTER TABLE orders
+    ALTER COLUMN date_created TYPE varchar(50)
+        USING to_char(date_created AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"');
+
+-- Restore the original text of values that could not be converted.
+UPDATE orders o
+SET date_created = r.raw_value
+FROM date_created_migration_report r
+WHERE o.order_uid = r.order_uid;
+
+UPDATE orders SET date_created = '' WHERE date_created IS NULL;
+
+ALTER TABLE orders ALTER COLUMN date_created SET NOT NULL;
+
+DROP TABLE date_created_migration_report;
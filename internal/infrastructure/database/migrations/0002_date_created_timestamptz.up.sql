@@ -0,0 +1,33 @@
+-- Convert orders.date_created from varchar to timestamptz.
+-- Values that are not valid RFC 3339 timestamps are recorded in
+-- date_created_migration_report with their original text and set to NULL,
+-- so no order is lost and the bad values can be fixed by hand.
+
+CREATE TABLE date_created_migration_report (
+    order_uid   varchar(255) PRIMARY KEY REFERENCES orders (order_uid) ON DELETE CASCADE,
+    raw_value   varchar(50)  NOT NULL,
+    reported_at timestamptz  NOT NULL DEFAULT now()
+);
+
+CREATE OR REPLACE FUNCTION pg_temp.parse_rfc3339(v text) RETURNS timestamptz AS $$
+BEGIN
+    IF v !~ '^\d{4}-\d{2}-\d{2}[Tt]\d{2}:\d{2}:\d{2}(\.\d+)?([Zz]|[+-]\d{2}:\d{2})$' THEN
+        RETURN NULL;
+    END IF;
+    RETURN v::timestamptz;
+EXCEPTION WHEN others THEN
+    -- Well-formed but impossible dates such as 2021-02-30.
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+INSERT INTO date_created_migration_report (order_uid, raw_value)
+SELECT order_uid, date_created
+FROM orders
+WHERE pg_temp.parse_rfc3339(date_created) IS NULL;
+
+ALTER TABLE orders
+    ALTER COLUMN date_created DROP NOT NULL,
+    ALTER COLUMN date_created TYPE timestamptz USING pg_temp.parse_rfc3339(date_created);
+
+CREATE INDEX idx_orders_date_created ON orders (date_created);
@@ -0,0 +1,68 @@
+-- Append-only history of orders: one full JSON snapshot per stored change.
+-- Existing orders get their current state as version 1, with source 'backfill'.
+
+CREATE TABLE order_versions (
+    order_uid  varchar(255) NOT NULL REFERENCES orders (order_uid) ON DELETE CASCADE,
+    version    integer      NOT NULL,
+    source     varchar(255) NOT NULL,
+    created_at timestamptz  NOT NULL DEFAULT now(),
+    snapshot   jsonb        NOT NULL,
+    PRIMARY KEY (order_uid, version)
+);
+
+INSERT INTO order_versions (order_uid, version, source, snapshot)
+SELECT o.order_uid, 1, 'backfill', jsonb_build_object(
+    'order_uid', o.order_uid,
+    'track_number', o.track_number,
+    'entry', o.entry,
+    'locale', o.locale,
+    'internal_signature', COALESCE(o.internal_signature, ''),
+    'customer_id', o.customer_id,
+    'delivery_service', o.delivery_service,
+    'shardkey', o.shardkey,
+    'sm_id', o.sm_id,
+    'date_created', o.date_created,
+    'oof_shard', o.oof_shard,
+    'delivery', jsonb_build_object(
+        'name', COALESCE(d.name, ''),
+        'phone', COALESCE(d.phone, ''),
+        'zip', COALESCE(d.zip, ''),
+        'city', COALESCE(d.city, ''),
+        'address', COALESCE(d.address, ''),
+        'region', COALESCE(d.region, ''),
+        'email', COALESCE(d.email, '')
+    ),
+    'payment', jsonb_build_object(
+        'transaction', COALESCE(p.transaction, ''),
+        'request_id', COALESCE(p.request_id, ''),
+        'currency', COALESCE(p.currency, ''),
+        'provider', COALESCE(p.provider, ''),
+        'amount', COALESCE(p.amount, 0),
+        'payment_dt', COALESCE(p.payment_dt, 0),
+        'bank', COALESCE(p.bank, ''),
+        'delivery_cost', COALESCE(p.delivery_cost, 0),
+        'goods_total', COALESCE(p.goods_total, 0),
+        'custom_fee', COALESCE(p.custom_fee, 0)
+    ),
+    'items', COALESCE((
+        SELECT jsonb_agg(jsonb_build_object(
+            'rid', COALESCE(i.rid, ''),
+            'chrt_id', i.chrt_id,
+            'track_number', i.track_number,
+            'price', i.price,
+            'name', i.name,
+            'sale', i.sale,
+            'size', COALESCE(i.size, ''),
+            'total_price', i.total_price,
+            'nm_id', i.nm_id,
+            'brand', i.brand,
+            'status', i.status
+        ) ORDER BY i.id)
+        FROM order_items oi
+        JOIN items i ON i.id = oi.item_id
+        WHERE oi.order_order_uid = o.order_uid
+    ), '[]'::jsonb)
+)
+FROM orders o
+LEFT JOIN deliveries d ON d.id = o.delivery_id
+LEFT JOIN payments p ON p.id = o.payment_id;
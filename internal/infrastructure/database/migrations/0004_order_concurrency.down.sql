@@ -0,0 +1,5 @@
+ALTER TABLE order_versions DROP COLUMN IF EXISTS source_time;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS source_time,
+    DROP COLUMN IF EXISTS version;
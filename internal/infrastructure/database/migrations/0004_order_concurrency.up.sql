@@ -0,0 +1,15 @@
+-- Optimistic concurrency for orders: version is incremented on every write and
+-- checked by the next one; source_time is when the producer emitted the stored
+-- change, so a late update carrying older data can be detected.
+-- Existing orders start at their latest history version with an unknown source time.
+
+ALTER TABLE orders
+    ADD COLUMN version     integer NOT NULL DEFAULT 0,
+    ADD COLUMN source_time timestamptz;
+
+UPDATE orders o
+SET version = v.version
+FROM (SELECT order_uid, MAX(version) AS version FROM order_versions GROUP BY order_uid) v
+WHERE v.order_uid = o.order_uid;
+
+ALTER TABLE order_versions ADD COLUMN source_time timestamptz;
@@ -0,0 +1,16 @@
+-- Order lifecycle: the current status on the order and every transition
+-- with the time it happened. Existing orders start as created.
+
+ALTER TABLE orders ADD COLUMN status varchar(20) NOT NULL DEFAULT 'created';
+
+CREATE TABLE order_status_events (
+    id          bigserial    PRIMARY KEY,
+    order_uid   varchar(255) NOT NULL REFERENCES orders (order_uid) ON DELETE CASCADE,
+    from_status varchar(20)  NOT NULL,
+    to_status   varchar(20)  NOT NULL,
+    occurred_at timestamptz  NOT NULL,
+    recorded_at timestamptz  NOT NULL DEFAULT now(),
+    source      varchar(255) NOT NULL
+);
+
+CREATE INDEX idx_order_status_events_order_uid ON order_status_events (order_uid, id);
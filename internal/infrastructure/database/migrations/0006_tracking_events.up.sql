@@ -0,0 +1,15 @@
+-- Carrier scan events per track number. Track numbers are not unique across
+-- orders and scans may arrive before the order, so there is no foreign key.
+
+CREATE TABLE tracking_events (
+    id               bigserial    PRIMARY KEY,
+    track_number     varchar(255) NOT NULL,
+    delivery_service varchar(255) NOT NULL,
+    code             varchar(50)  NOT NULL,
+    location         varchar(255) NOT NULL,
+    occurred_at      timestamptz  NOT NULL,
+    recorded_at      timestamptz  NOT NULL DEFAULT now(),
+    source           varchar(255) NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_tracking_events_scan ON tracking_events (track_number, code, occurred_at);
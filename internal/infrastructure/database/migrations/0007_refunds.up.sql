@@ -0,0 +1,18 @@
+-- Refunds of order payments, optionally for returned items. refund_id is the
+-- producer's ID of the refund, so a resent refund is stored once.
+
+CREATE TABLE refunds (
+    id          bigserial    PRIMARY KEY,
+    refund_id   varchar(255) NOT NULL,
+    order_uid   varchar(255) NOT NULL REFERENCES orders (order_uid) ON DELETE CASCADE,
+    transaction varchar(255) NOT NULL,
+    amount      bigint       NOT NULL CHECK (amount > 0),
+    item_rids   jsonb        NOT NULL DEFAULT '[]',
+    reason      varchar(255) NOT NULL DEFAULT '',
+    refunded_at timestamptz  NOT NULL,
+    recorded_at timestamptz  NOT NULL DEFAULT now(),
+    source      varchar(255) NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_refunds_refund_id ON refunds (refund_id);
+CREATE INDEX idx_refunds_order_uid ON refunds (order_uid, id);
@@ -0,0 +1,15 @@
+-- Orders can only reference one payment again: the first one is kept and the
+-- others are deleted.
+
+ALTER TABLE orders ADD COLUMN payment_id bigint;
+
+UPDATE orders
+SET payment_id = (SELECT min(id) FROM payments WHERE payments.order_uid = orders.order_uid);
+
+DELETE FROM payments WHERE id NOT IN (SELECT payment_id FROM orders WHERE payment_id IS NOT NULL);
+
+ALTER TABLE orders ADD CONSTRAINT fk_orders_payment FOREIGN KEY (payment_id) REFERENCES payments (id);
+
+DROP INDEX IF EXISTS idx_payments_order_transaction;
+DROP INDEX IF EXISTS idx_payments_order_uid;
+ALTER TABLE payments DROP COLUMN order_uid;
@@ -0,0 +1,22 @@
+-- Orders may have several payments (e.g. a gift card and a card, or
+-- instalments), so payments reference their order instead of the order
+-- referencing a single payment. Payments no order refers to are leftovers of
+-- earlier updates and are removed.
+
+ALTER TABLE payments ADD COLUMN order_uid varchar(255);
+
+UPDATE payments
+SET order_uid = orders.order_uid
+FROM orders
+WHERE orders.payment_id = payments.id;
+
+ALTER TABLE orders DROP COLUMN payment_id;
+
+DELETE FROM payments WHERE order_uid IS NULL;
+
+ALTER TABLE payments
+    ALTER COLUMN order_uid SET NOT NULL,
+    ADD CONSTRAINT fk_orders_payments FOREIGN KEY (order_uid) REFERENCES orders (order_uid) ON DELETE CASCADE;
+
+CREATE INDEX idx_payments_order_uid ON payments (order_uid);
+CREATE UNIQUE INDEX idx_payments_order_transaction ON payments (order_uid, transaction);
@@ -0,0 +1,19 @@
+-- Items get a surrogate key again and are linked to orders by order_items.
+-- Deleted orphans and duplicates are not restored.
+
+ALTER TABLE items DROP CONSTRAINT items_pkey;
+ALTER TABLE items ADD COLUMN id bigserial PRIMARY KEY;
+ALTER TABLE items ALTER COLUMN rid DROP NOT NULL;
+
+CREATE TABLE order_items (
+    order_order_uid varchar(255),
+    item_id         bigint,
+    PRIMARY KEY (order_order_uid, item_id),
+    CONSTRAINT fk_order_items_order FOREIGN KEY (order_order_uid) REFERENCES orders (order_uid) ON DELETE CASCADE,
+    CONSTRAINT fk_order_items_item  FOREIGN KEY (item_id)         REFERENCES items (id)         ON DELETE CASCADE
+);
+
+INSERT INTO order_items (order_order_uid, item_id)
+SELECT order_uid, id FROM items;
+
+ALTER TABLE items DROP COLUMN order_uid;
@@ -0,0 +1,33 @@
+-- Items belong to exactly one order and are identified by their RID within
+-- it, so the order_items join table is replaced by items.order_uid with
+-- (order_uid, rid) as the primary key.
+--
+-- Updates used to insert the items of an order again and keep the old rows
+-- linked, so for each order and RID only the latest row (highest id) is kept.
+-- Rows not linked to any order, superseded duplicates and items without a RID
+-- are deleted.
+
+ALTER TABLE items ADD COLUMN order_uid varchar(255);
+
+UPDATE items
+SET order_uid = latest.order_uid
+FROM (
+    SELECT DISTINCT ON (oi.order_order_uid, i.rid) oi.order_order_uid AS order_uid, i.id
+    FROM order_items oi
+    JOIN items i ON i.id = oi.item_id
+    WHERE i.rid IS NOT NULL
+    ORDER BY oi.order_order_uid, i.rid, i.id DESC
+) latest
+WHERE items.id = latest.id;
+
+DELETE FROM items WHERE order_uid IS NULL;
+
+DROP TABLE order_items;
+
+ALTER TABLE items DROP COLUMN id;
+
+ALTER TABLE items
+    ALTER COLUMN order_uid SET NOT NULL,
+    ALTER COLUMN rid SET NOT NULL,
+    ADD PRIMARY KEY (order_uid, rid),
+    ADD CONSTRAINT fk_orders_items FOREIGN KEY (order_uid) REFERENCES orders (order_uid) ON DELETE CASCADE;
@@ -1,17 +1,18 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"order/internal/config"
-	"order/internal/model"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	gormtracing "gorm.io/plugin/opentelemetry/tracing"
 )
 
-// NewPostgresDB initializes a new PostgreSQL connection, performs migrations,
-// and ensures necessary indexes exist.
-func NewPostgresDB(cfg config.DBConfig) (*gorm.DB, error) {
+// OpenPostgres opens a PostgreSQL connection and checks that it is reachable.
+// It does not touch the schema.
+func OpenPostgres(cfg config.DBConfig) (*gorm.DB, error) {
 	dsn := fmt.Sprintf(
 		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
 		cfg.Host, cfg.User, cfg.Password, cfg.Name, cfg.Port,
@@ -31,13 +32,34 @@ func NewPostgresDB(cfg config.DBConfig) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to ping DB: %w", err)
 	}
 
-	if err := db.AutoMigrate(
-		&model.Order{},
-		&model.Delivery{},
-		&model.Payment{},
-		&model.Item{},
-	); err != nil {
-		return nil, fmt.Errorf("failed to perform migrations: %w", err)
+	return db, nil
+}
+
+// NewPostgresDB initializes a new PostgreSQL connection and verifies that
+// every versioned migration has been applied. Schema changes are made only
+// by `orderctl migrate up`, never at service startup.
+// SQL statements are traced; bind values are left out of spans as they hold personal data.
+func NewPostgresDB(cfg config.DBConfig) (*gorm.DB, error) {
+	db, err := OpenPostgres(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DB object: %w", err)
+	}
+
+	migrator, err := NewMigrator(sqlDB)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrator.Check(context.Background()); err != nil {
+		return nil, err
+	}
+
+	if err := db.Use(gormtracing.NewPlugin(gormtracing.WithoutQueryVariables(), gormtracing.WithoutMetrics())); err != nil {
+		return nil, fmt.Errorf("failed to enable SQL tracing: %w", err)
 	}
 
 	return db, nil
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"order/internal/metrics"
+	"order/internal/model"
+	"order/internal/tracing"
+
+	"gorm.io/gorm"
+)
+
+// ErrPaymentNotFound is returned by AddRefund when no stored order was paid by the refunded transaction.
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// ErrDuplicateRefund is returned by AddRefund when a refund with the same refund ID is already stored.
+var ErrDuplicateRefund = errors.New("refund already recorded")
+
+// AddRefund records a refund of the order paid by refund.Transaction, if check
+// accepts it against the order and its previous refunds. Like any other change,
+// the refund is recorded as a new order version, so concurrent refunds of an
+// order are checked one after the other and can't together exceed the payment.
+// It returns the updated order, or ErrPaymentNotFound, ErrDuplicateRefund or
+// the error of check without writing anything.
+func (r *Repository) AddRefund(ctx context.Context, refund *model.Refund, source model.Source, check func(order *model.Order) error) (*model.Order, error) {
+	defer metrics.ObserveQuery("add_refund", time.Now())
+
+	ctx, span := tracing.Tracer().Start(ctx, "Repository.AddRefund")
+	defer span.End()
+
+	order, err := retryOnVersionConflict(ctx, "add_refund", func() (*model.Order, error) {
+		return r.addRefund(ctx, refund, source, check)
+	})
+	if order != nil {
+		span.SetAttributes(tracing.OrderUID.String(order.OrderUID))
+	}
+	if err != nil && !errors.Is(err, ErrDuplicateRefund) {
+		tracing.RecordError(span, err)
+	}
+	return order, err
+}
+
+// addRefund makes a single attempt at recording the refund. It returns
+// errVersionConflict if another writer changed the order since it was read.
+func (r *Repository) addRefund(ctx context.Context, refund *model.Refund, source model.Source, check func(order *model.Order) error) (*model.Order, error) {
+	var uids []string
+	if err := r.transactionQuery(ctx, refund.Transaction).Limit(1).Pluck("orders.order_uid", &uids).Error; err != nil {
+		return nil, err
+	}
+	if len(uids) == 0 {
+		return nil, ErrPaymentNotFound
+	}
+	order, err := r.getOrder(ctx, uids[0])
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrPaymentNotFound
+	}
+
+	var known int64
+	if err := r.db.WithContext(ctx).Model(&model.Refund{}).Where("refund_id = ?", refund.RefundID).Count(&known).Error; err != nil {
+		return nil, err
+	}
+	if known > 0 {
+		return order, ErrDuplicateRefund
+	}
+	if err := check(order); err != nil {
+		return order, err
+	}
+
+	stored := *refund
+	stored.OrderUID = order.OrderUID
+	next := *order
+	next.Version = order.Version + 1
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := claimVersion(tx, order.OrderUID, order.Version); err != nil {
+			return err
+		}
+		if err := tx.Create(&stored).Error; err != nil {
+			return err
+		}
+		next.Refunds = append(slices.Clone(order.Refunds), stored)
+		next.UpdateNetAmount()
+		return appendVersion(tx, &next, source)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	*refund = stored
+	r.cache.Set(ctx, next.OrderUID, &next)
+	slog.Info("refund recorded",
+		slog.String("uid", order.OrderUID),
+		slog.String("refund_id", refund.RefundID),
+		slog.Int("amount", refund.Amount),
+		slog.Int("net_amount", next.NetAmount),
+	)
+	return &next, nil
+}
@@ -1,33 +1,47 @@
 package database
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
 	"order/internal/infrastructure/cache"
+	"order/internal/metrics"
 	"order/internal/model"
+	"order/internal/tracing"
 
 	"gorm.io/gorm"
 )
 
+// ErrDuplicateOrder is returned by AddOrder when the stored order already has identical content.
+var ErrDuplicateOrder = errors.New("order with identical content already stored")
+
 // Repository wraps database and cache access for orders.
 type Repository struct {
-	db    *gorm.DB
-	cache *cache.OrderCache
+	db     *gorm.DB
+	cache  *cache.OrderCache
+	policy ConflictPolicy
 }
 
 // NewRepository creates a new instance of Repository with database and cache dependencies.
-func NewRepository(db *gorm.DB, cache *cache.OrderCache) *Repository {
-	return &Repository{db: db, cache: cache}
+// policy decides how AddOrder handles updates older than the stored order.
+func NewRepository(db *gorm.DB, cache *cache.OrderCache, policy ConflictPolicy) *Repository {
+	return &Repository{db: db, cache: cache, policy: policy}
 }
 
 // getOrder retrieves an order directly from the database by UID
-// including related entities (Delivery, Payment, and Items).
-func (r *Repository) getOrder(uid string) (*model.Order, error) {
+// including related entities (Delivery, Payments, Items and Refunds).
+func (r *Repository) getOrder(ctx context.Context, uid string) (*model.Order, error) {
+	defer metrics.ObserveQuery("get_order", time.Now())
+
 	var order model.Order
-	err := r.db.Preload("Delivery").
-		Preload("Payment").
-		Preload("Items").
-		First(&order, "order_uid = ?", uid).Error
+	err := withAssociations(r.db.WithContext(ctx)).First(&order, "order_uid = ?", uid).Error
 
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -38,79 +52,298 @@ func (r *Repository) getOrder(uid string) (*model.Order, error) {
 		return nil, err
 	}
 
+	order.UpdateNetAmount()
 	slog.Debug("order loaded from db", slog.String("uid", uid))
 	return &order, nil
 }
 
+// withAssociations preloads the related entities an order is returned with.
+func withAssociations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Delivery").
+		Preload("Payments", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("rid") }).
+		Preload("Refunds", func(db *gorm.DB) *gorm.DB { return db.Order("id") })
+}
+
 // GetOrderWithCache tries to get an order from cache first.
 // If cache miss occurs, it loads the order from DB and updates cache.
-func (r *Repository) GetOrderWithCache(uid string) (*model.Order, error) {
-	if cached, ok := r.cache.Get(uid); ok {
+func (r *Repository) GetOrderWithCache(ctx context.Context, uid string) (*model.Order, error) {
+	if cached, ok := r.cache.Get(ctx, uid); ok {
 		slog.Debug("cache hit", slog.String("uid", uid))
 		return cached, nil
 	}
 
 	slog.Debug("cache miss", slog.String("uid", uid))
-	order, err := r.getOrder(uid)
+	order, err := r.getOrder(ctx, uid)
 	if order != nil {
-		r.cache.Set(uid, order)
+		r.cache.Set(ctx, uid, order)
 		slog.Debug("cache updated after db load", slog.String("uid", uid))
 	}
 	return order, err
 }
 
 // saveNewOrder inserts a new order into the database.
-// Associations (Delivery, Payment, Items) are also stored automatically.
+// Associations (Delivery, Payments, Items) are also stored automatically;
+// refunds are only ever stored by AddRefund.
 func (r *Repository) saveNewOrder(tx *gorm.DB, order *model.Order) error {
 	slog.Info("inserting new order", slog.String("uid", order.OrderUID))
-	return tx.Create(order).Error
+	return tx.Omit("Refunds").Create(order).Error
 }
 
-// saveExistingOrder updates an existing order and all its associations.
-// FullSaveAssociations ensures Delivery, Payment, and Items are updated together.
+// saveExistingOrder updates an existing order and its associations but items and refunds.
+// FullSaveAssociations ensures Delivery and Payments are updated together;
+// items are written by syncItems.
 func (r *Repository) saveExistingOrder(tx *gorm.DB, order *model.Order) error {
 	slog.Info("updating existing order",
 		slog.String("uid", order.OrderUID),
 		slog.Int("items_count", len(order.Items)),
 	)
-	return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(order).Error
+	return tx.Session(&gorm.Session{FullSaveAssociations: true}).Omit("Items", "Refunds").Save(order).Error
+}
+
+// contentHash returns a SHA-256 fingerprint of the order's JSON representation.
+// Database-only fields are excluded from JSON, the status and refunds are
+// managed by their own events and items are stored by RID, so redeliveries hash
+// identically.
+func contentHash(order *model.Order) (string, error) {
+	o := *order
+	o.Items = sortedItems(order.Items)
+	o.Status = ""
+	o.Refunds = nil
+	o.NetAmount = 0
+	raw, err := json.Marshal(&o)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// sortedItems returns a copy of items in RID order, the order they are loaded in.
+func sortedItems(items []model.Item) []model.Item {
+	sorted := slices.Clone(items)
+	slices.SortFunc(sorted, func(a, b model.Item) int { return strings.Compare(a.Rid, b.Rid) })
+	return sorted
 }
 
-// AddOrder inserts or updates an order inside a transaction.
+// AddOrder inserts or updates an order and appends a snapshot of it to the
+// order history, attributed to source.
+//
+// Writes are optimistic: the stored order is read without locks, and the write
+// only succeeds if the order's version is still the one that was read (or, for
+// a new order, if nobody inserted it meanwhile). Otherwise the order is read
+// again and the decision repeated, so concurrent writers never lose updates.
+//
+// If the stored order has the same content hash, nothing is written and
+// ErrDuplicateOrder is returned together with the stored order. If the update
+// is older than the stored order, the conflict policy applies and
+// ErrOrderConflict or ErrStaleOrder may be returned the same way.
 // After successful save, the order is refreshed in cache.
-func (r *Repository) AddOrder(order *model.Order) (*model.Order, error) {
-	existingOrder, err := r.getOrder(order.OrderUID)
+func (r *Repository) AddOrder(ctx context.Context, order *model.Order, source model.Source) (*model.Order, error) {
+	defer metrics.ObserveQuery("add_order", time.Now())
+
+	ctx, span := tracing.Tracer().Start(ctx, "Repository.AddOrder")
+	defer span.End()
+	span.SetAttributes(tracing.OrderUID.String(order.OrderUID))
+
+	hash, err := contentHash(order)
 	if err != nil {
+		tracing.RecordError(span, err)
 		return nil, err
 	}
 
-	err = r.db.Transaction(func(tx *gorm.DB) error {
-		if existingOrder == nil {
-			return r.saveNewOrder(tx, order)
-		}
-		return r.saveExistingOrder(tx, order)
+	stored, err := retryOnVersionConflict(ctx, "add_order", func() (*model.Order, error) {
+		return r.saveOrder(ctx, order, hash, source)
 	})
+	if err != nil {
+		if !errors.Is(err, ErrDuplicateOrder) && !errors.Is(err, ErrStaleOrder) {
+			tracing.RecordError(span, err)
+		}
+		return stored, err
+	}
 
+	// Update cache immediately with the latest order object.
+	r.cache.Set(ctx, order.OrderUID, order)
+	slog.Info("order saved and cached", slog.String("uid", order.OrderUID), slog.Int("version", order.Version))
+
+	return order, nil
+}
+
+// saveOrder makes a single attempt at storing the order. It returns
+// errVersionConflict if another writer changed the order since it was read.
+func (r *Repository) saveOrder(ctx context.Context, order *model.Order, hash string, source model.Source) (*model.Order, error) {
+	existingOrder, err := r.getOrder(ctx, order.OrderUID)
 	if err != nil {
-		slog.Error("failed to save order",
-			slog.String("uid", order.OrderUID),
-			slog.Any("err", err),
-		)
 		return nil, err
 	}
 
-	// Update cache immediately with the latest order object.
-	r.cache.Set(order.OrderUID, order)
-	slog.Info("order saved and cached", slog.String("uid", order.OrderUID))
+	if existingOrder != nil {
+		if existingOrder.ContentHash == hash {
+			slog.Debug("order content unchanged", slog.String("uid", order.OrderUID))
+			return existingOrder, ErrDuplicateOrder
+		}
+		if err := r.policy.checkConflict(existingOrder, source); err != nil {
+			slog.Warn("stale order update",
+				slog.String("uid", order.OrderUID),
+				slog.String("source", source.Name),
+				slog.String("policy", string(r.policy)),
+			)
+			return existingOrder, err
+		}
+	}
+
+	// Work on a copy, so IDs assigned by a rolled back attempt don't leak into the next one.
+	next := *order
+	next.Items = sortedItems(order.Items)
+	next.Payments = slices.Clone(order.Payments)
+	next.ContentHash = hash
+	next.SourceTime = model.NewTimestamp(source.Time)
+	next.Version = 1
+	next.Status = model.StatusCreated
+	next.Refunds = nil
+	if existingOrder != nil {
+		next.Version = existingOrder.Version + 1
+		next.Status = existingOrder.Status
+		next.Refunds = existingOrder.Refunds
+	}
+	next.UpdateNetAmount()
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if existingOrder == nil {
+			if err := r.saveNewOrder(tx, &next); err != nil {
+				if isDuplicateOrderInsert(err) {
+					return errVersionConflict
+				}
+				return err
+			}
+		} else {
+			if err := claimVersion(tx, next.OrderUID, existingOrder.Version); err != nil {
+				return err
+			}
+			if err := syncPayments(tx, &next, existingOrder); err != nil {
+				return err
+			}
+			if err := syncItems(tx, &next, existingOrder); err != nil {
+				return err
+			}
+			if err := r.saveExistingOrder(tx, &next); err != nil {
+				return err
+			}
+		}
+		return appendVersion(tx, &next, source)
+	})
+	if err != nil {
+		if !errors.Is(err, errVersionConflict) {
+			slog.Error("failed to save order",
+				slog.String("uid", order.OrderUID),
+				slog.Any("err", err),
+			)
+		}
+		return nil, err
+	}
 
+	*order = next
 	return order, nil
 }
 
+// syncPayments matches the payments of the update to the stored ones by
+// transaction, so they are updated in place, and deletes stored payments the
+// update no longer has.
+func syncPayments(tx *gorm.DB, next, existing *model.Order) error {
+	ids := make(map[string]uint, len(existing.Payments))
+	for _, p := range existing.Payments {
+		ids[p.Transaction] = p.ID
+	}
+
+	keep := make([]uint, 0, len(next.Payments))
+	for i := range next.Payments {
+		if id, ok := ids[next.Payments[i].Transaction]; ok {
+			next.Payments[i].ID = id
+			keep = append(keep, id)
+		}
+	}
+
+	query := tx.Where("order_uid = ?", next.OrderUID)
+	if len(keep) > 0 {
+		query = query.Where("id NOT IN ?", keep)
+	}
+	return query.Delete(&model.Payment{}).Error
+}
+
+// syncItems writes the difference between the stored items and the items of
+// the update, matched by RID: new items are inserted, changed ones updated and
+// the ones the update no longer has deleted. Unchanged items are not written.
+func syncItems(tx *gorm.DB, next, existing *model.Order) error {
+	stored := make(map[string]model.Item, len(existing.Items))
+	for _, it := range existing.Items {
+		stored[it.Rid] = it
+	}
+
+	var inserts []model.Item
+	updated := 0
+	for i := range next.Items {
+		it := &next.Items[i]
+		it.OrderUID = next.OrderUID
+		old, ok := stored[it.Rid]
+		delete(stored, it.Rid)
+		switch {
+		case !ok:
+			inserts = append(inserts, *it)
+		case *it != old:
+			if err := tx.Save(it).Error; err != nil {
+				return err
+			}
+			updated++
+		}
+	}
+
+	if len(inserts) > 0 {
+		if err := tx.Create(&inserts).Error; err != nil {
+			return err
+		}
+	}
+	if len(stored) > 0 {
+		rids := make([]string, 0, len(stored))
+		for rid := range stored {
+			rids = append(rids, rid)
+		}
+		if err := tx.Where("order_uid = ? AND rid IN ?", next.OrderUID, rids).Delete(&model.Item{}).Error; err != nil {
+			return err
+		}
+	}
+
+	slog.Debug("order items synced",
+		slog.String("uid", next.OrderUID),
+		slog.Int("inserted", len(inserts)),
+		slog.Int("updated", updated),
+		slog.Int("deleted", len(stored)),
+	)
+	return nil
+}
+
+// claimVersion bumps the order's version if it is still the expected one,
+// locking the order row until the transaction ends. It returns
+// errVersionConflict if another writer got there first.
+func claimVersion(tx *gorm.DB, uid string, expected int) error {
+	res := tx.Model(&model.Order{}).
+		Where("order_uid = ? AND version = ?", uid, expected).
+		Update("version", expected+1)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errVersionConflict
+	}
+	return nil
+}
+
 // RestoreCache loads orders from DB into cache.
 // If maxSize > 0, only the latest maxSize orders are loaded.
-func (r *Repository) RestoreCache(maxSize int) error {
+func (r *Repository) RestoreCache(ctx context.Context, maxSize int) error {
+	defer metrics.ObserveQuery("restore_cache", time.Now())
+
 	var orders []model.Order
-	query := r.db.Preload("Delivery").Preload("Payment").Preload("Items").Order("date_created DESC")
+	query := withAssociations(r.db.WithContext(ctx)).Order("date_created DESC NULLS LAST")
 	if maxSize > 0 {
 		query = query.Limit(maxSize)
 	}
@@ -119,9 +352,19 @@ func (r *Repository) RestoreCache(maxSize int) error {
 	}
 
 	for i := range orders {
-		r.cache.Set(orders[i].OrderUID, &orders[i])
+		orders[i].UpdateNetAmount()
+		r.cache.Set(ctx, orders[i].OrderUID, &orders[i])
 	}
 
 	slog.Info("cache restored from database", slog.Int("orders_count", len(orders)))
 	return nil
 }
+
+// Ping checks that the database accepts connections.
+func (r *Repository) Ping(ctx context.Context) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,274 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"order/internal/infrastructure/cache"
+	"order/internal/model"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB connects to the Postgres given by TEST_DB_DSN (key=value form) and
+// migrates a schema of its own, dropped after the test. Tests using it are
+// skipped when TEST_DB_DSN is not set.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+	config := &gorm.Config{Logger: logger.Discard}
+
+	admin, err := gorm.Open(postgres.Open(dsn), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := admin.Exec("DROP SCHEMA " + schema + " CASCADE").Error; err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	db, err := gorm.Open(postgres.Open(dsn+" search_path="+schema), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migrator, err := NewMigrator(sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newTestRepository creates a Repository on a fresh test schema.
+func newTestRepository(t *testing.T, policy ConflictPolicy) *Repository {
+	t.Helper()
+	orderCache, err := cache.NewOrderCache(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewRepository(testDB(t), orderCache, policy)
+}
+
+// testOrder returns a complete order with one payment and one item.
+func testOrder(uid string) *model.Order {
+	return &model.Order{
+		OrderUID:        uid,
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     model.NewTimestamp(time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)),
+		OofShard:        "1",
+		Delivery: model.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payments: []model.Payment{{
+			Transaction:  uid,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		}},
+		Items: []model.Item{{
+			Rid:         "ab4219087a764ae0btest",
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+	}
+}
+
+func TestAddOrderConcurrentWrites(t *testing.T) {
+	// Every lost race costs a writer one attempt, so this many writers all succeed.
+	const writers = maxConflictAttempts
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, existing := range []bool{false, true} {
+		t.Run(fmt.Sprintf("existing=%t", existing), func(t *testing.T) {
+			ctx := context.Background()
+			repo := newTestRepository(t, ConflictReject)
+			const uid = "b563feb7b2b84b6test"
+
+			want := []string{}
+			if existing {
+				if _, err := repo.AddOrder(ctx, testOrder(uid), model.Source{Name: "test/initial", Time: base}); err != nil {
+					t.Fatal(err)
+				}
+				want = append(want, "test")
+			}
+
+			start := make(chan struct{})
+			errs := make([]error, writers)
+			var wg sync.WaitGroup
+			for i := range writers {
+				order := testOrder(uid)
+				order.CustomerID = fmt.Sprintf("writer%d", i)
+				want = append(want, order.CustomerID)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					_, errs[i] = repo.AddOrder(ctx, order, model.Source{Name: order.CustomerID, Time: base.Add(time.Minute)})
+				}()
+			}
+			close(start)
+			wg.Wait()
+
+			for i, err := range errs {
+				if err != nil {
+					t.Errorf("writer %d: %v", i, err)
+				}
+			}
+
+			// Every write is its own version: none was lost or stored twice.
+			history, err := repo.GetOrderHistory(ctx, uid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for i, v := range history {
+				if v.Version != i+1 {
+					t.Errorf("history[%d] is version %d, want %d", i, v.Version, i+1)
+				}
+				got = append(got, v.Snapshot.CustomerID)
+			}
+			slices.Sort(got)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("versions were written by %v, want %v", got, want)
+			}
+
+			stored, err := repo.getOrder(ctx, uid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Version != len(want) {
+				t.Errorf("stored version = %d, want %d", stored.Version, len(want))
+			}
+			if stored.CustomerID != history[len(history)-1].Snapshot.CustomerID {
+				t.Errorf("stored order is from %s, the latest version from %s", stored.CustomerID, history[len(history)-1].Snapshot.CustomerID)
+			}
+			cached, err := repo.GetOrderWithCache(ctx, uid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cached.Version != stored.Version {
+				t.Errorf("cached version = %d, want %d", cached.Version, stored.Version)
+			}
+		})
+	}
+}
+
+func TestAddOrderStaleSource(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		policy       ConflictPolicy
+		wantErr      error
+		wantVersion  int
+		wantCustomer string
+	}{
+		{ConflictReject, ErrOrderConflict, 1, "test"},
+		{ConflictKeepNewest, ErrStaleOrder, 1, "test"},
+		{ConflictOverwrite, nil, 2, "stale"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			ctx := context.Background()
+			repo := newTestRepository(t, tt.policy)
+			const uid = "b563feb7b2b84b6test"
+
+			if _, err := repo.AddOrder(ctx, testOrder(uid), model.Source{Name: "test/new", Time: base}); err != nil {
+				t.Fatal(err)
+			}
+
+			update := testOrder(uid)
+			update.CustomerID = "stale"
+			_, err := repo.AddOrder(ctx, update, model.Source{Name: "test/stale", Time: base.Add(-time.Hour)})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, err := repo.getOrder(ctx, uid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Version != tt.wantVersion || stored.CustomerID != tt.wantCustomer {
+				t.Errorf("stored version %d from %s, want version %d from %s",
+					stored.Version, stored.CustomerID, tt.wantVersion, tt.wantCustomer)
+			}
+		})
+	}
+}
+
+func TestGetOrderByItemRIDSharedAcrossOrders(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, ConflictReject)
+	source := model.Source{Name: "test", Time: time.Now()}
+
+	older := testOrder("b563feb7b2b84b6older")
+	newer := testOrder("b563feb7b2b84b6newer")
+	newer.DateCreated = model.NewTimestamp(older.DateCreated.Add(time.Hour))
+	// Store the older order first, so an unordered scan would find it first.
+	for _, o := range []*model.Order{older, newer} {
+		if _, err := repo.AddOrder(ctx, o, source); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for range 3 {
+		got, err := repo.GetOrderByItemRID(ctx, older.Items[0].Rid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == nil || got.OrderUID != newer.OrderUID {
+			t.Fatalf("GetOrderByItemRID = %v, want the most recent order %s", got, newer.OrderUID)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"order/internal/metrics"
+	"order/internal/model"
+	"order/internal/tracing"
+
+	"gorm.io/gorm"
+)
+
+// ErrOrderNotFound is returned when an operation refers to an order that is not stored.
+var ErrOrderNotFound = errors.New("order not found")
+
+// ErrStatusUnchanged is returned by ChangeOrderStatus when the order already has the requested status.
+var ErrStatusUnchanged = errors.New("order already has this status")
+
+// ErrStaleStatus is returned by ChangeOrderStatus when the event happened before
+// the last status change of the order, so it arrived too late to apply.
+var ErrStaleStatus = errors.New("status event is older than the last status change")
+
+// ChangeOrderStatus moves an order to the status of the event, if allow accepts
+// the transition from the current status. The change is recorded in
+// order_status_events and, like any other change, as a new order version.
+// It returns ErrOrderNotFound, ErrStatusUnchanged, ErrStaleStatus or the error
+// of allow without writing anything.
+func (r *Repository) ChangeOrderStatus(ctx context.Context, event *model.StatusEvent, source model.Source, allow func(from model.OrderStatus) error) (*model.StatusChange, error) {
+	defer metrics.ObserveQuery("change_status", time.Now())
+
+	ctx, span := tracing.Tracer().Start(ctx, "Repository.ChangeOrderStatus")
+	defer span.End()
+	span.SetAttributes(tracing.OrderUID.String(event.OrderUID))
+
+	change, err := retryOnVersionConflict(ctx, "change_status", func() (*model.StatusChange, error) {
+		return r.changeOrderStatus(ctx, event, source, allow)
+	})
+	if err != nil && !errors.Is(err, ErrStatusUnchanged) && !errors.Is(err, ErrStaleStatus) {
+		tracing.RecordError(span, err)
+	}
+	return change, err
+}
+
+// changeOrderStatus makes a single attempt at changing the status. It returns
+// errVersionConflict if another writer changed the order since it was read.
+func (r *Repository) changeOrderStatus(ctx context.Context, event *model.StatusEvent, source model.Source, allow func(from model.OrderStatus) error) (*model.StatusChange, error) {
+	order, err := r.getOrder(ctx, event.OrderUID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+	if order.Status == event.Status {
+		return nil, ErrStatusUnchanged
+	}
+	// Changes are only applied in time order, so the latest one has the latest time.
+	var last []model.StatusChange
+	if err := r.db.WithContext(ctx).Where("order_uid = ?", order.OrderUID).Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+		return nil, err
+	}
+	if len(last) > 0 && event.OccurredAt.Before(last[0].OccurredAt.Time) {
+		return nil, ErrStaleStatus
+	}
+	if err := allow(order.Status); err != nil {
+		return nil, err
+	}
+
+	change := model.StatusChange{
+		OrderUID:   order.OrderUID,
+		FromStatus: order.Status,
+		ToStatus:   event.Status,
+		OccurredAt: event.OccurredAt,
+		Source:     source.Name,
+	}
+	next := *order
+	next.Status = event.Status
+	next.Version = order.Version + 1
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := claimVersion(tx, order.OrderUID, order.Version); err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Order{}).Where("order_uid = ?", order.OrderUID).Update("status", event.Status).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&change).Error; err != nil {
+			return err
+		}
+		return appendVersion(tx, &next, source)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache.Set(ctx, next.OrderUID, &next)
+	slog.Info("order status changed",
+		slog.String("uid", order.OrderUID),
+		slog.String("from", string(change.FromStatus)),
+		slog.String("to", string(change.ToStatus)),
+	)
+	return &change, nil
+}
+
+// GetStatusHistory returns the status transitions of an order, oldest first.
+func (r *Repository) GetStatusHistory(ctx context.Context, uid string) ([]model.StatusChange, error) {
+	defer metrics.ObserveQuery("status_history", time.Now())
+
+	var changes []model.StatusChange
+	err := r.db.WithContext(ctx).
+		Where("order_uid = ?", uid).
+		Order("id").
+		Find(&changes).Error
+	if err != nil {
+		slog.Error("db error on status history", slog.String("uid", uid), slog.Any("err", err))
+		return nil, err
+	}
+	return changes, nil
+}
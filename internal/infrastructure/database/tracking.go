@@ -0,0 +1,46 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"order/internal/metrics"
+	"order/internal/model"
+
+	"gorm.io/gorm/clause"
+)
+
+// AddTrackingEvent stores a carrier scan. It returns false if the same scan
+// (track number, code and time) is already stored.
+func (r *Repository) AddTrackingEvent(ctx context.Context, event *model.TrackingEvent) (bool, error) {
+	defer metrics.ObserveQuery("add_tracking_event", time.Now())
+
+	res := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "track_number"}, {Name: "code"}, {Name: "occurred_at"}},
+			DoNothing: true,
+		}).
+		Create(event)
+	if res.Error != nil {
+		slog.Error("failed to store tracking event", slog.String("track_number", event.TrackNumber), slog.Any("err", res.Error))
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// GetTrackingTimeline returns the scans of a track number in the order they happened.
+func (r *Repository) GetTrackingTimeline(ctx context.Context, trackNumber string) ([]model.TrackingEvent, error) {
+	defer metrics.ObserveQuery("tracking_timeline", time.Now())
+
+	var events []model.TrackingEvent
+	err := r.db.WithContext(ctx).
+		Where("track_number = ?", trackNumber).
+		Order("occurred_at, id").
+		Find(&events).Error
+	if err != nil {
+		slog.Error("db error on tracking timeline", slog.String("track_number", trackNumber), slog.Any("err", err))
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,129 @@
+// Package metrics defines the Prometheus metrics of the service.
+// Label values are limited to bounded sets (topics, partitions, route
+// templates, statuses, rule names, operations) to keep cardinality low.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "orders"
+
+// Kafka consumer metrics.
+var (
+	KafkaMessagesConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "messages_consumed_total",
+		Help:      "Kafka messages fetched by the consumer.",
+	}, []string{"topic"})
+
+	KafkaHandleDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "handle_duration_seconds",
+		Help:      "Time spent by the batch handler on a single Kafka message.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	KafkaCommitFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "commit_failures_total",
+		Help:      "Offset commits that failed.",
+	}, []string{"topic"})
+
+	KafkaConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumer_lag",
+		Help:      "Messages behind the partition high watermark as of the last fetched message.",
+	}, []string{"topic", "partition"})
+
+	DLQWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "dlq_writes_total",
+		Help:      "Messages written to the dead letter queue by cause (decode, rejected, failed, conflict).",
+	}, []string{"cause"})
+)
+
+// Ingestion and validation metrics.
+var (
+	OrdersIngested = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ingested_total",
+		Help:      "Orders run through the ingestion pipeline by result status.",
+	}, []string{"status"})
+
+	RuleViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rule_violations_total",
+		Help:      "Validation rule violations by rule and severity.",
+	}, []string{"rule", "severity"})
+)
+
+// Order cache metrics.
+var (
+	CacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "hits_total",
+		Help:      "Order cache lookups that found the order.",
+	})
+
+	CacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "misses_total",
+		Help:      "Order cache lookups that did not find the order.",
+	})
+
+	CacheEvictions = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "evictions_total",
+		Help:      "Orders evicted from the cache to make room for new ones.",
+	})
+
+	CacheSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "size",
+		Help:      "Orders currently held in the cache.",
+	})
+)
+
+// DBQueryDuration measures Repository operations.
+var DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Subsystem: "db",
+	Name:      "query_duration_seconds",
+	Help:      "Repository operation latency by operation.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"operation"})
+
+// HTTPRequestDuration measures HTTP requests by route template, method and status code.
+var HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Subsystem: "http",
+	Name:      "request_duration_seconds",
+	Help:      "HTTP request latency by route, method and status.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"route", "method", "status"})
+
+// ObserveQuery records the duration of a Repository operation started at start.
+// Use it as `defer metrics.ObserveQuery("get_order", time.Now())`.
+func ObserveQuery(operation string, start time.Time) {
+	DBQueryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -1,26 +1,61 @@
 package model
 
+import "encoding/json"
+
 // Order represents a customer order
 type Order struct {
-	OrderUID          string `json:"order_uid"          validate:"required" gorm:"type:varchar(255);primaryKey"`
-	TrackNumber       string `json:"track_number"       validate:"required,alphanumunicode" gorm:"type:varchar(255);not null"`
-	Entry             string `json:"entry"              validate:"required,alphanumunicode" gorm:"type:varchar(50);not null"`
-	Locale            string `json:"locale"             validate:"required" gorm:"type:varchar(10);not null"`
-	InternalSignature string `json:"internal_signature" validate:"-" gorm:"type:varchar(255)"`
-	CustomerID        string `json:"customer_id"        validate:"required,alphanumunicode,min=1,max=64" gorm:"type:varchar(255);not null"`
-	DeliveryService   string `json:"delivery_service"   validate:"required,ascii,max=50" gorm:"type:varchar(255);not null"`
-	Shardkey          string `json:"shardkey"           validate:"required,numeric" gorm:"type:varchar(50);not null"`
-	SmID              int    `json:"sm_id"              validate:"required,gt=0" gorm:"not null"`
-	DateCreated       string `json:"date_created"       validate:"required" gorm:"type:varchar(50);not null"`
-	OofShard          string `json:"oof_shard"          validate:"required,numeric" gorm:"type:varchar(50);not null"`
+	OrderUID          string    `json:"order_uid"          validate:"required" gorm:"type:varchar(255);primaryKey"`
+	TrackNumber       string    `json:"track_number"       validate:"required,alphanumunicode" gorm:"type:varchar(255);not null;index:idx_orders_track_number"`
+	Entry             string    `json:"entry"              validate:"required,alphanumunicode" gorm:"type:varchar(50);not null"`
+	Locale            string    `json:"locale"             validate:"required,locale" gorm:"type:varchar(10);not null"`
+	InternalSignature string    `json:"internal_signature" validate:"-" gorm:"type:varchar(255)"`
+	CustomerID        string    `json:"customer_id"        validate:"required,alphanumunicode,min=1,max=64" gorm:"type:varchar(255);not null"`
+	DeliveryService   string    `json:"delivery_service"   validate:"required,ascii,max=50" gorm:"type:varchar(255);not null"`
+	Shardkey          string    `json:"shardkey"           validate:"required,numeric" gorm:"type:varchar(50);not null"`
+	SmID              int       `json:"sm_id"              validate:"required,gt=0" gorm:"not null"`
+	DateCreated       Timestamp `json:"date_created"       validate:"required,rfc3339" gorm:"type:timestamptz;index:idx_orders_date_created"`
+	OofShard          string    `json:"oof_shard"          validate:"required,numeric" gorm:"type:varchar(50);not null"`
+
+	// Status is managed by status events; a status sent with the order is ignored.
+	Status OrderStatus `json:"status" validate:"-" gorm:"type:varchar(20);not null;default:created"`
 
 	DeliveryID uint     `json:"-"`
 	Delivery   Delivery `json:"delivery"                   gorm:"foreignKey:DeliveryID"`
 
-	PaymentID uint    `json:"-"`
-	Payment   Payment `json:"payment"                      gorm:"foreignKey:PaymentID"`
+	// Payments are all payments of the order, e.g. a gift card and a card, or instalments.
+	Payments []Payment `json:"payments" validate:"required,min=1,unique=Transaction,dive" gorm:"foreignKey:OrderUID;references:OrderUID;constraint:OnDelete:CASCADE"`
+
+	// Items belong to the order and are identified by their RID within it.
+	Items []Item `json:"items" validate:"unique=Rid,dive" gorm:"foreignKey:OrderUID;references:OrderUID;constraint:OnDelete:CASCADE"`
+
+	// Refunds are recorded by refund events and NetAmount is the amount paid
+	// minus the refunds; both are ignored when sent with the order.
+	Refunds   []Refund `json:"refunds,omitempty" validate:"-" gorm:"foreignKey:OrderUID;references:OrderUID"`
+	NetAmount int      `json:"net_amount"        validate:"-" gorm:"-"`
+
+	ContentHash string `json:"-" gorm:"type:varchar(64)"`
+
+	// Version is incremented on every stored change and guards concurrent updates.
+	// SourceTime is when the producer emitted the stored change.
+	Version    int       `json:"-" gorm:"not null;default:0"`
+	SourceTime Timestamp `json:"-" gorm:"type:timestamptz"`
+}
 
-	Items []Item `json:"items" gorm:"many2many:order_items;foreignKey:OrderUID;constraint:OnDelete:CASCADE"`
+// UnmarshalJSON decodes an order, accepting the single "payment" object of
+// older payloads as the only payment. If both are sent, "payments" wins.
+func (o *Order) UnmarshalJSON(data []byte) error {
+	type order Order
+	aux := struct {
+		*order
+		Payment *Payment `json:"payment"`
+	}{order: (*order)(o)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.Payment != nil && len(o.Payments) == 0 {
+		o.Payments = []Payment{*aux.Payment}
+	}
+	return nil
 }
 
 // Delivery represents delivery details for an order
@@ -35,25 +70,26 @@ type Delivery struct {
 	Email   string `json:"email"   validate:"required,email"         gorm:"type:varchar(255);not null"`
 }
 
-// Payment represents payment details for an order
+// Payment represents one payment of an order
 type Payment struct {
 	ID           uint   `json:"-"             validate:"-"                     gorm:"primaryKey;autoIncrement"`
-	Transaction  string `json:"transaction"   validate:"required"              gorm:"type:varchar(255);not null"`
+	OrderUID     string `json:"-"             validate:"-"                     gorm:"type:varchar(255);not null;index:idx_payments_order_uid"`
+	Transaction  string `json:"transaction"   validate:"required"              gorm:"type:varchar(255);not null;index:idx_payments_transaction"`
 	RequestID    string `json:"request_id"    validate:"-"                     gorm:"type:varchar(255)"`
-	Currency     string `json:"currency"      validate:"required"              gorm:"type:varchar(10);not null"`
+	Currency     string `json:"currency"      validate:"required,currency"     gorm:"type:varchar(10);not null"`
 	Provider     string `json:"provider"      validate:"required,ascii,max=50" gorm:"type:varchar(50);not null"`
 	Amount       int    `json:"amount"        validate:"required,gt=0"         gorm:"not null"`
 	PaymentDt    int64  `json:"payment_dt"    validate:"required,gt=0"         gorm:"not null"`
 	Bank         string `json:"bank"          validate:"required,max=50"       gorm:"type:varchar(50);not null"`
-	DeliveryCost int    `json:"delivery_cost" validate:"required,gte=0"        gorm:"not null"`
-	GoodsTotal   int    `json:"goods_total"   validate:"required,gte=0"        gorm:"not null"`
+	DeliveryCost int    `json:"delivery_cost" validate:"gte=0"                 gorm:"not null"`
+	GoodsTotal   int    `json:"goods_total"   validate:"gte=0"                 gorm:"not null"`
 	CustomFee    int    `json:"custom_fee"    validate:"gte=0"                 gorm:"not null"`
 }
 
 // Item represents an individual item within an order
 type Item struct {
-	ID          uint   `json:"-"            validate:"-"                                     gorm:"primaryKey;autoIncrement"`
-	Rid         string `json:"rid"          validate:"required,alphanumunicode,min=5,max=64" gorm:"type:varchar(255)"`
+	OrderUID    string `json:"-"            validate:"-"                                     gorm:"type:varchar(255);primaryKey"`
+	Rid         string `json:"rid"          validate:"required,alphanumunicode,min=5,max=64" gorm:"type:varchar(255);primaryKey;index:idx_items_rid"`
 	ChrtID      int    `json:"chrt_id"      validate:"required,gte=0"                        gorm:"not null"`
 	TrackNumber string `json:"track_number" validate:"required,alphanumunicode,max=32"       gorm:"type:varchar(255);not null"`
 	Price       int    `json:"price"        validate:"required,gt=0"                         gorm:"not null"`
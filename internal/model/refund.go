@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// Refund returns part or all of a payment, optionally for returned items.
+// RefundID is the producer's ID of the refund, so a resent refund is stored once.
+type Refund struct {
+	ID          uint      `json:"-"                validate:"-" gorm:"primaryKey;autoIncrement"`
+	RefundID    string    `json:"refund_id"        validate:"required,max=255" gorm:"type:varchar(255);not null"`
+	OrderUID    string    `json:"-"                validate:"-" gorm:"type:varchar(255);not null"`
+	Transaction string    `json:"transaction"      validate:"required,max=255" gorm:"type:varchar(255);not null"`
+	Amount      int       `json:"amount"           validate:"gt=0" gorm:"not null"`
+	ItemRIDs    []string  `json:"item_rids"        validate:"unique,dive,required" gorm:"column:item_rids;type:jsonb;serializer:json;not null"`
+	Reason      string    `json:"reason,omitempty" validate:"max=255" gorm:"type:varchar(255);not null"`
+	RefundedAt  Timestamp `json:"refunded_at"      validate:"required,rfc3339" gorm:"type:timestamptz;not null"`
+	RecordedAt  time.Time `json:"recorded_at"      validate:"-" gorm:"type:timestamptz;not null;autoCreateTime"`
+	Source      string    `json:"source"           validate:"-" gorm:"type:varchar(255);not null"`
+}
+
+// Paid returns the total amount of the order's payments.
+func (o *Order) Paid() int {
+	total := 0
+	for _, p := range o.Payments {
+		total += p.Amount
+	}
+	return total
+}
+
+// Refunded returns the total amount of the order's refunds.
+func (o *Order) Refunded() int {
+	total := 0
+	for _, r := range o.Refunds {
+		total += r.Amount
+	}
+	return total
+}
+
+// UpdateNetAmount sets NetAmount to what was paid minus what was refunded.
+func (o *Order) UpdateNetAmount() {
+	o.NetAmount = o.Paid() - o.Refunded()
+}
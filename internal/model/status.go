@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// OrderStatus is a stage of the order lifecycle.
+type OrderStatus string
+
+// Order lifecycle stages. New orders start as created; cancelled and returned are final.
+const (
+	StatusCreated    OrderStatus = "created"
+	StatusPaid       OrderStatus = "paid"
+	StatusAssembling OrderStatus = "assembling"
+	StatusShipped    OrderStatus = "shipped"
+	StatusDelivered  OrderStatus = "delivered"
+	StatusCancelled  OrderStatus = "cancelled"
+	StatusReturned   OrderStatus = "returned"
+)
+
+// StatusEvent reports that an order moved to a new status at OccurredAt.
+type StatusEvent struct {
+	OrderUID   string      `json:"order_uid"   validate:"required"`
+	Status     OrderStatus `json:"status"      validate:"required,oneof=created paid assembling shipped delivered cancelled returned"`
+	OccurredAt Timestamp   `json:"occurred_at" validate:"required,rfc3339"`
+}
+
+// StatusChange is a stored status transition of an order.
+type StatusChange struct {
+	ID         uint        `json:"-"           gorm:"primaryKey;autoIncrement"`
+	OrderUID   string      `json:"-"           gorm:"type:varchar(255);not null"`
+	FromStatus OrderStatus `json:"from"        gorm:"type:varchar(20);not null"`
+	ToStatus   OrderStatus `json:"to"          gorm:"type:varchar(20);not null"`
+	OccurredAt Timestamp   `json:"occurred_at" gorm:"type:timestamptz;not null"`
+	RecordedAt time.Time   `json:"recorded_at" gorm:"type:timestamptz;not null;autoCreateTime"`
+	Source     string      `json:"source"      gorm:"type:varchar(255);not null"`
+}
+
+// TableName keeps the table name in line with the event it stores.
+func (StatusChange) TableName() string {
+	return "order_status_events"
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Timestamp is an RFC 3339 time stored as timestamptz.
+// Decoding never fails on a malformed value: the raw text is kept so that
+// validation can report it as a violation instead of rejecting the whole batch.
+type Timestamp struct {
+	time.Time
+	raw string
+}
+
+// NewTimestamp wraps t into a Timestamp.
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t}
+}
+
+// Parsed reports whether the timestamp holds a parsed time.
+func (t Timestamp) Parsed() bool {
+	return t.raw == "" && !t.IsZero()
+}
+
+// String returns the timestamp in RFC 3339 format, the raw text if it could
+// not be parsed, or "" if it is empty.
+func (t Timestamp) String() string {
+	if t.raw != "" {
+		return t.raw
+	}
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// utc returns the timestamp converted to UTC. Unparsed timestamps are returned unchanged.
+func (t Timestamp) utc() Timestamp {
+	if !t.Parsed() {
+		return t
+	}
+	return NewTimestamp(t.UTC())
+}
+
+// UnmarshalJSON parses an RFC 3339 string, keeping the raw text when it is malformed.
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	*t = Timestamp{}
+	if string(data) == "null" {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.raw = string(data)
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.raw = s
+		return nil
+	}
+	t.Time = parsed
+	return nil
+}
+
+// MarshalJSON writes the timestamp as an RFC 3339 string, or null if it is empty.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.raw == "" && t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.String())
+}
+
+// Scan implements sql.Scanner.
+func (t *Timestamp) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*t = Timestamp{}
+	case time.Time:
+		*t = Timestamp{Time: v}
+	default:
+		return fmt.Errorf("cannot scan %T into Timestamp", value)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer. Empty and unparsed timestamps are stored as NULL.
+func (t Timestamp) Value() (driver.Value, error) {
+	if !t.Parsed() {
+		return nil, nil
+	}
+	return t.Time, nil
+}
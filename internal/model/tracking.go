@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// TrackingEvent is a carrier scan of a shipment, linked to orders by track number.
+// A scan is identified by its track number, code and time, so resent scans are stored once.
+type TrackingEvent struct {
+	ID              uint      `json:"-"                validate:"-" gorm:"primaryKey;autoIncrement"`
+	TrackNumber     string    `json:"track_number"     validate:"required,max=255" gorm:"type:varchar(255);not null"`
+	DeliveryService string    `json:"delivery_service" validate:"required,max=255" gorm:"type:varchar(255);not null"`
+	Code            string    `json:"code"             validate:"required,max=50" gorm:"type:varchar(50);not null"`
+	Location        string    `json:"location"         validate:"required,max=255" gorm:"type:varchar(255);not null"`
+	OccurredAt      Timestamp `json:"occurred_at"      validate:"required,rfc3339" gorm:"type:timestamptz;not null"`
+	RecordedAt      time.Time `json:"recorded_at"      validate:"-" gorm:"type:timestamptz;not null;autoCreateTime"`
+	Source          string    `json:"source"           validate:"-" gorm:"type:varchar(255);not null"`
+}
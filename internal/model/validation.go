@@ -0,0 +1,53 @@
+package model
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// Severity tells whether a violated rule rejects an order or only produces a warning.
+type Severity string
+
+// Supported violation severities.
+const (
+	SeverityReject Severity = "reject"
+	SeverityWarn   Severity = "warn"
+)
+
+// Violation describes a single failed validation rule.
+// Path is the JSON path of the offending field, e.g. "items[2].price".
+// Value holds the actual value, redacted for personal data.
+type Violation struct {
+	Path     string   `json:"path"`
+	Rule     string   `json:"rule"`
+	Code     string   `json:"code,omitempty"`
+	Severity Severity `json:"severity,omitempty"`
+	Value    any      `json:"value,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// ValidationError is returned when an order fails validation.
+type ValidationError struct {
+	Violations []Violation `json:"violations"`
+}
+
+// Error joins all violations into a single line.
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, v.Path+": "+v.Message)
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// LogValue renders the violations as structured log attributes.
+func (e *ValidationError) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		attrs = append(attrs, slog.Group(v.Path,
+			slog.String("rule", v.Rule),
+			slog.String("message", v.Message),
+		))
+	}
+	return slog.GroupValue(attrs...)
+}
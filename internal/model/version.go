@@ -0,0 +1,112 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// Source identifies where an order change came from: Name is e.g.
+// "kafka:orders/0@42" or "http:10.0.0.1", Time is when the producer emitted
+// the change, or zero if unknown.
+type Source struct {
+	Name string
+	Time time.Time
+}
+
+// OrderVersion is an immutable snapshot of an order, taken every time the order is stored.
+// Versions of an order are numbered from 1 without gaps.
+type OrderVersion struct {
+	OrderUID   string    `json:"order_uid"   gorm:"type:varchar(255);primaryKey"`
+	Version    int       `json:"version"     gorm:"primaryKey;autoIncrement:false"`
+	Source     string    `json:"source"      gorm:"type:varchar(255);not null"`
+	SourceTime Timestamp `json:"source_time" gorm:"type:timestamptz"`
+	CreatedAt  time.Time `json:"created_at"  gorm:"type:timestamptz;not null"`
+	Snapshot   Order     `json:"snapshot"    gorm:"type:jsonb;serializer:json;not null"`
+}
+
+// Change is a single field that differs between two order versions.
+// Path uses the same notation as Violation.Path; From or To is nil when the
+// field (usually an item) exists in only one of the versions.
+type Change struct {
+	Path string `json:"path"`
+	From any    `json:"from"`
+	To   any    `json:"to"`
+}
+
+// DiffOrders lists the fields that differ between two orders, sorted by path.
+// Orders are compared by their JSON representation, so database-only fields are
+// ignored, and creation dates are compared in UTC.
+func DiffOrders(from, to *Order) ([]Change, error) {
+	x, y := *from, *to
+	x.DateCreated, y.DateCreated = x.DateCreated.utc(), y.DateCreated.utc()
+
+	a, err := toJSONValue(&x)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toJSONValue(&y)
+	if err != nil {
+		return nil, err
+	}
+	changes := []Change{}
+	diffValues("", a, b, &changes)
+	return changes, nil
+}
+
+// toJSONValue converts v to the generic form produced by json.Unmarshal into an any.
+func toJSONValue(v any) (any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	err = json.Unmarshal(raw, &out)
+	return out, err
+}
+
+// diffValues appends the changes between a and b found below path.
+func diffValues(path string, a, b any, changes *[]Change) {
+	switch av := a.(type) {
+	case map[string]any:
+		if bv, ok := b.(map[string]any); ok {
+			keys := make([]string, 0, len(av)+len(bv))
+			for k := range av {
+				keys = append(keys, k)
+			}
+			for k := range bv {
+				if _, ok := av[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				child := k
+				if path != "" {
+					child = path + "." + k
+				}
+				diffValues(child, av[k], bv[k], changes)
+			}
+			return
+		}
+	case []any:
+		if bv, ok := b.([]any); ok {
+			for i := range max(len(av), len(bv)) {
+				var x, y any
+				if i < len(av) {
+					x = av[i]
+				}
+				if i < len(bv) {
+					y = bv[i]
+				}
+				diffValues(fmt.Sprintf("%s[%d]", path, i), x, y, changes)
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, Change{Path: path, From: a, To: b})
+	}
+}
@@ -0,0 +1,98 @@
+// Package refdata provides reference lists used by order validation: ISO 4217
+// currencies, ISO 639-1 languages and postal code formats by country.
+// The lists are embedded in the binary, so validation works offline.
+package refdata
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//go:embed currencies.json languages.json postal_codes.json
+var files embed.FS
+
+// Currency is an ISO 4217 currency.
+type Currency struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// PostalFormat is the postal code format of a country.
+// Names lists lowercase region names that refer to the country besides its ISO 3166 code.
+// Names that are also regions of other countries, like Georgia (a US state), are left
+// out, so such regions only match by code.
+type PostalFormat struct {
+	Country string   `json:"country"`
+	Names   []string `json:"names"`
+	Pattern string   `json:"pattern"`
+
+	re *regexp.Regexp
+}
+
+// Match reports whether zip has the country's postal code format.
+func (f PostalFormat) Match(zip string) bool {
+	return f.re.MatchString(zip)
+}
+
+var (
+	currencies    = map[string]Currency{}
+	languages     = map[string]bool{}
+	postalFormats = map[string]PostalFormat{}
+)
+
+func init() {
+	var list []Currency
+	mustLoad("currencies.json", &list)
+	for _, c := range list {
+		currencies[c.Code] = c
+	}
+
+	var codes []string
+	mustLoad("languages.json", &codes)
+	for _, code := range codes {
+		languages[code] = true
+	}
+
+	var formats []PostalFormat
+	mustLoad("postal_codes.json", &formats)
+	for _, f := range formats {
+		f.re = regexp.MustCompile(f.Pattern)
+		postalFormats[strings.ToLower(f.Country)] = f
+		for _, name := range f.Names {
+			postalFormats[name] = f
+		}
+	}
+}
+
+// mustLoad decodes an embedded JSON file. The files ship with the binary,
+// so a decoding error is a build defect.
+func mustLoad(name string, v any) {
+	data, err := files.ReadFile(name)
+	if err != nil {
+		panic(fmt.Sprintf("refdata: %v", err))
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		panic(fmt.Sprintf("refdata: invalid %s: %v", name, err))
+	}
+}
+
+// LookupCurrency returns the ISO 4217 currency with the given upper-case code.
+func LookupCurrency(code string) (Currency, bool) {
+	c, ok := currencies[code]
+	return c, ok
+}
+
+// IsLanguage reports whether code is a lowercase ISO 639-1 language code.
+func IsLanguage(code string) bool {
+	return languages[code]
+}
+
+// LookupPostalFormat returns the postal code format for a delivery region given
+// as an ISO 3166 country code or country name. Matching is case-insensitive.
+func LookupPostalFormat(region string) (PostalFormat, bool) {
+	f, ok := postalFormats[strings.ToLower(strings.TrimSpace(region))]
+	return f, ok
+}
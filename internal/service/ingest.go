@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"order/internal/infrastructure/database"
+	"order/internal/metrics"
+	"order/internal/model"
+	"order/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Result statuses reported for every ingested order.
+// Duplicates are orders whose identical content was already stored, stale orders
+// are updates older than the stored order that were skipped; both count as success.
+// Conflicts are such updates refused under the "reject" conflict policy.
+const (
+	StatusAccepted  = "accepted"
+	StatusDuplicate = "duplicate"
+	StatusStale     = "stale"
+	StatusConflict  = "conflict"
+	StatusRejected  = "rejected"
+	StatusFailed    = "failed"
+)
+
+// OrderStore persists orders. AddOrder returns database.ErrDuplicateOrder
+// when identical content is already stored, and database.ErrStaleOrder or
+// database.ErrOrderConflict when the update is older than the stored order.
+// source identifies where the order came from and is kept in the order history.
+type OrderStore interface {
+	AddOrder(ctx context.Context, order *model.Order, source model.Source) (*model.Order, error)
+}
+
+// ValidateFunc validates a single order and returns a non-nil error if it is invalid.
+type ValidateFunc func(o *model.Order) error
+
+// StructValidator validates an event by its validate tags and reports failures
+// as a *model.ValidationError.
+type StructValidator func(v any) error
+
+// OrderResult describes what happened to a single order during ingestion.
+// Rejected orders list their validation violations; failed orders carry a short error message.
+type OrderResult struct {
+	OrderUID   string            `json:"order_uid"`
+	Status     string            `json:"status"`
+	Violations []model.Violation `json:"violations,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Err        error             `json:"-"`
+}
+
+// IngestService validates and stores orders. It is shared by the Kafka consumer
+// and the HTTP ingestion endpoint so both follow the same pipeline.
+type IngestService struct {
+	store    OrderStore
+	validate ValidateFunc
+}
+
+// NewIngestService creates a new IngestService with the given store and validator.
+func NewIngestService(store OrderStore, validate ValidateFunc) *IngestService {
+	return &IngestService{store: store, validate: validate}
+}
+
+// Ingest validates every order and stores the valid ones, recording source
+// in the order history.
+// It returns one result per order in the same order as the input.
+// ctx carries the trace; cancelling it does not interrupt a started batch,
+// so an order is never left half-processed.
+func (s *IngestService) Ingest(ctx context.Context, source model.Source, orders []model.Order) []OrderResult {
+	ctx = context.WithoutCancel(ctx)
+	results := make([]OrderResult, 0, len(orders))
+	for i := range orders {
+		res := s.ingestOne(ctx, source, &orders[i])
+		metrics.OrdersIngested.WithLabelValues(res.Status).Inc()
+		results = append(results, res)
+	}
+	return results
+}
+
+// ingestOne runs a single order through validation and persistence.
+func (s *IngestService) ingestOne(ctx context.Context, source model.Source, order *model.Order) OrderResult {
+	ctx, span := tracing.Tracer().Start(ctx, "IngestService.ingest")
+	defer span.End()
+	span.SetAttributes(tracing.OrderUID.String(order.OrderUID))
+
+	res := s.ingest(ctx, source, order)
+	span.SetAttributes(attribute.String("order.status", res.Status))
+	if res.Err != nil {
+		tracing.RecordError(span, res.Err)
+	}
+	return res
+}
+
+// ingest validates and stores a single order.
+func (s *IngestService) ingest(ctx context.Context, source model.Source, order *model.Order) OrderResult {
+	res := OrderResult{OrderUID: order.OrderUID}
+
+	_, span := tracing.Tracer().Start(ctx, "validate")
+	err := s.validate(order)
+	span.End()
+	if err != nil {
+		slog.Warn("order rejected", slog.String("uid", order.OrderUID), slog.Any("error", err))
+		res.Status = StatusRejected
+		res.Err = err
+		var verr *model.ValidationError
+		if errors.As(err, &verr) {
+			res.Violations = verr.Violations
+		} else {
+			res.Error = err.Error()
+		}
+		return res
+	}
+
+	_, err = s.store.AddOrder(ctx, order, source)
+	if errors.Is(err, database.ErrDuplicateOrder) {
+		slog.Info("duplicate order skipped", slog.String("uid", order.OrderUID))
+		res.Status = StatusDuplicate
+		return res
+	}
+	if errors.Is(err, database.ErrStaleOrder) {
+		slog.Info("stale order skipped", slog.String("uid", order.OrderUID), slog.String("source", source.Name))
+		res.Status = StatusStale
+		return res
+	}
+	if errors.Is(err, database.ErrOrderConflict) {
+		slog.Warn("order conflict", slog.String("uid", order.OrderUID), slog.Any("error", err))
+		res.Status = StatusConflict
+		res.Error = err.Error()
+		res.Err = err
+		return res
+	}
+	if err != nil {
+		slog.Error("order not stored", slog.String("uid", order.OrderUID), slog.Any("err", err))
+		res.Status = StatusFailed
+		res.Error = "failed to store order"
+		res.Err = err
+		return res
+	}
+
+	res.Status = StatusAccepted
+	return res
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"order/internal/infrastructure/database"
+	"order/internal/model"
+)
+
+// RefundStore records refunds. AddRefund calls check with the refunded order
+// and writes nothing if it returns an error.
+type RefundStore interface {
+	AddRefund(ctx context.Context, refund *model.Refund, source model.Source, check func(order *model.Order) error) (*model.Order, error)
+}
+
+// RefundService records refunds and item returns against order payments.
+type RefundService struct {
+	store    RefundStore
+	validate StructValidator
+}
+
+// NewRefundService creates a new RefundService with the given store and refund validator.
+func NewRefundService(store RefundStore, validate StructValidator) *RefundService {
+	return &RefundService{store: store, validate: validate}
+}
+
+// Refund validates a refund and records it against the payment it references,
+// returning the updated order. Malformed refunds, refunds exceeding what is
+// left of the payment and returns of items that are not in the order or were
+// already returned are reported as a *model.ValidationError.
+// A resent refund returns database.ErrDuplicateRefund with the stored order.
+func (s *RefundService) Refund(ctx context.Context, source model.Source, refund *model.Refund) (*model.Order, error) {
+	if err := s.validate(refund); err != nil {
+		return nil, err
+	}
+
+	refund.ID = 0
+	refund.OrderUID = ""
+	refund.Source = source.Name
+	if refund.ItemRIDs == nil {
+		refund.ItemRIDs = []string{}
+	}
+	return s.store.AddRefund(ctx, refund, source, func(order *model.Order) error {
+		return checkRefund(order, refund)
+	})
+}
+
+// Record is Refund for event consumers: resent refunds are ignored.
+func (s *RefundService) Record(ctx context.Context, source model.Source, refund *model.Refund) error {
+	_, err := s.Refund(ctx, source, refund)
+	if errors.Is(err, database.ErrDuplicateRefund) {
+		slog.Info("refund already recorded", slog.String("refund_id", refund.RefundID))
+		return nil
+	}
+	return err
+}
+
+// checkRefund checks a refund against the payment it refunds and the order's previous refunds.
+func checkRefund(order *model.Order, refund *model.Refund) error {
+	var violations []model.Violation
+
+	i := slices.IndexFunc(order.Payments, func(p model.Payment) bool { return p.Transaction == refund.Transaction })
+	if i < 0 {
+		// The payment was removed by an order update since it was looked up.
+		return database.ErrPaymentNotFound
+	}
+	paid, refunded := order.Payments[i].Amount, 0
+	for _, r := range order.Refunds {
+		if r.Transaction == refund.Transaction {
+			refunded += r.Amount
+		}
+	}
+	if refunded+refund.Amount > paid {
+		violations = append(violations, model.Violation{
+			Path: "amount", Rule: "refundable", Code: "REFUND_EXCEEDS_PAYMENT", Severity: model.SeverityReject, Value: refund.Amount,
+			Message: fmt.Sprintf("exceeds the refundable amount of %d (paid %d, already refunded %d)", paid-refunded, paid, refunded),
+		})
+	}
+
+	returned := make(map[string]bool)
+	for _, r := range order.Refunds {
+		for _, rid := range r.ItemRIDs {
+			returned[rid] = true
+		}
+	}
+	for i, rid := range refund.ItemRIDs {
+		path := fmt.Sprintf("item_rids[%d]", i)
+		switch {
+		case !slices.ContainsFunc(order.Items, func(item model.Item) bool { return item.Rid == rid }):
+			violations = append(violations, model.Violation{
+				Path: path, Rule: "order_item", Code: "REFUND_UNKNOWN_ITEM", Severity: model.SeverityReject, Value: rid,
+				Message: "is not an item of order " + order.OrderUID,
+			})
+		case returned[rid]:
+			violations = append(violations, model.Violation{
+				Path: path, Rule: "not_returned", Code: "ITEM_ALREADY_RETURNED", Severity: model.SeverityReject, Value: rid,
+				Message: "has already been returned",
+			})
+		}
+	}
+	if len(violations) > 0 {
+		return &model.ValidationError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"order/internal/infrastructure/database"
+	"order/internal/model"
+)
+
+// transitions lists the statuses each status may move to. Statuses without
+// an entry (cancelled, returned) are final.
+var transitions = map[model.OrderStatus][]model.OrderStatus{
+	model.StatusCreated:    {model.StatusPaid, model.StatusCancelled},
+	model.StatusPaid:       {model.StatusAssembling, model.StatusCancelled},
+	model.StatusAssembling: {model.StatusShipped, model.StatusCancelled},
+	model.StatusShipped:    {model.StatusDelivered, model.StatusReturned},
+	model.StatusDelivered:  {model.StatusReturned},
+}
+
+// CanTransition reports whether the order lifecycle allows moving from one status to another.
+func CanTransition(from, to model.OrderStatus) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// StatusStore changes and records order statuses. ChangeOrderStatus calls allow
+// with the current status and writes nothing if it returns an error.
+type StatusStore interface {
+	ChangeOrderStatus(ctx context.Context, event *model.StatusEvent, source model.Source, allow func(from model.OrderStatus) error) (*model.StatusChange, error)
+}
+
+// StatusService applies status events to orders, enforcing the order lifecycle.
+type StatusService struct {
+	store    StatusStore
+	validate StructValidator
+}
+
+// NewStatusService creates a new StatusService with the given store and event validator.
+func NewStatusService(store StatusStore, validate StructValidator) *StatusService {
+	return &StatusService{store: store, validate: validate}
+}
+
+// Apply validates a status event and moves the order to its status.
+// Malformed events and transitions the lifecycle forbids are reported as a
+// *model.ValidationError. Events repeating the current status are ignored,
+// so redelivered events are harmless, and so are events older than the last
+// status change, which arrived out of order.
+func (s *StatusService) Apply(ctx context.Context, source model.Source, event *model.StatusEvent) error {
+	if err := s.validate(event); err != nil {
+		return err
+	}
+
+	_, err := s.store.ChangeOrderStatus(ctx, event, source, func(from model.OrderStatus) error {
+		if CanTransition(from, event.Status) {
+			return nil
+		}
+		return &model.ValidationError{Violations: []model.Violation{{
+			Path:     "status",
+			Rule:     "transition",
+			Code:     "INVALID_STATUS_TRANSITION",
+			Severity: model.SeverityReject,
+			Value:    event.Status,
+			Message:  fmt.Sprintf("order cannot move from %s to %s", from, event.Status),
+		}}}
+	})
+	switch {
+	case errors.Is(err, database.ErrStatusUnchanged):
+		slog.Info("order status unchanged", slog.String("uid", event.OrderUID), slog.String("status", string(event.Status)))
+		return nil
+	case errors.Is(err, database.ErrStaleStatus):
+		slog.Info("stale status event skipped",
+			slog.String("uid", event.OrderUID),
+			slog.String("status", string(event.Status)),
+			slog.String("occurred_at", event.OccurredAt.String()),
+		)
+		return nil
+	}
+	return err
+}
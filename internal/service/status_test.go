@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+
+	"order/internal/model"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to model.OrderStatus
+		want     bool
+	}{
+		{model.StatusCreated, model.StatusPaid, true},
+		{model.StatusCreated, model.StatusCancelled, true},
+		{model.StatusPaid, model.StatusAssembling, true},
+		{model.StatusAssembling, model.StatusShipped, true},
+		{model.StatusShipped, model.StatusDelivered, true},
+		{model.StatusShipped, model.StatusReturned, true},
+		{model.StatusDelivered, model.StatusReturned, true},
+
+		// Skipping a step.
+		{model.StatusCreated, model.StatusAssembling, false},
+		{model.StatusCreated, model.StatusDelivered, false},
+		{model.StatusCreated, model.StatusReturned, false},
+		{model.StatusPaid, model.StatusShipped, false},
+		{model.StatusAssembling, model.StatusDelivered, false},
+		// Going back.
+		{model.StatusPaid, model.StatusCreated, false},
+		{model.StatusDelivered, model.StatusShipped, false},
+		// Leaving a final status.
+		{model.StatusCancelled, model.StatusPaid, false},
+		{model.StatusReturned, model.StatusDelivered, false},
+		// Staying put is not a transition.
+		{model.StatusPaid, model.StatusPaid, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %t, want %t", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"order/internal/model"
+)
+
+// TrackingStore stores carrier scans. AddTrackingEvent returns false if the scan is already stored.
+type TrackingStore interface {
+	AddTrackingEvent(ctx context.Context, event *model.TrackingEvent) (bool, error)
+}
+
+// TrackingService records carrier scan events for the shipment timeline.
+type TrackingService struct {
+	store    TrackingStore
+	validate StructValidator
+}
+
+// NewTrackingService creates a new TrackingService with the given store and event validator.
+func NewTrackingService(store TrackingStore, validate StructValidator) *TrackingService {
+	return &TrackingService{store: store, validate: validate}
+}
+
+// Record validates a scan event and stores it, attributed to source.
+// Malformed events are reported as a *model.ValidationError; resent scans are ignored.
+func (s *TrackingService) Record(ctx context.Context, source model.Source, event *model.TrackingEvent) error {
+	if err := s.validate(event); err != nil {
+		return err
+	}
+
+	event.ID = 0
+	event.RecordedAt = time.Time{}
+	event.Source = source.Name
+	added, err := s.store.AddTrackingEvent(ctx, event)
+	if err != nil {
+		return err
+	}
+	if !added {
+		slog.Info("tracking event already recorded", slog.String("track_number", event.TrackNumber), slog.String("code", event.Code))
+		return nil
+	}
+	slog.Info("tracking event recorded",
+		slog.String("track_number", event.TrackNumber),
+		slog.String("code", event.Code),
+		slog.String("location", event.Location),
+	)
+	return nil
+}